@@ -5,6 +5,7 @@ import (
 	mirc "github.com/padeir0/pir/backends/linuxamd64/mir/class"
 	FT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
 	IT "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
 
 	"fmt"
@@ -112,10 +113,16 @@ type amd64Instr struct {
 	Instr string
 	Op1   string
 	Op2   string
+
+	// if Instr is empty, this is printed as a line comment
+	Comment string
 }
 
 func (this *amd64Instr) String() string {
 	if this.Instr == "" {
+		if this.Comment != "" {
+			return "; " + this.Comment
+		}
 		return "???"
 	}
 	if this.Op1 == "" {
@@ -134,14 +141,20 @@ const (
 	//signed
 	IMul = "imul"
 	IDiv = "idiv"
+	Cbw  = "cbw"
+	Cwd  = "cwd"
+	Cdq  = "cdq"
+	Cqo  = "cqo"
 	//unsigned
 	Mul = "mul"
 	Div = "div"
 
-	Mov   = "mov"
-	Movsx = "movsx"
-	Push  = "push"
-	Pop   = "pop"
+	Mov    = "mov"
+	Movsx  = "movsx"
+	Movsxd = "movsxd"
+	Movzx  = "movzx"
+	Push   = "push"
+	Pop    = "pop"
 
 	Not = "not"
 	And = "and"
@@ -149,6 +162,7 @@ const (
 	Xor = "xor"
 	Sal = "sal"
 	Sar = "sar"
+	Shr = "shr"
 
 	Cmp = "cmp"
 
@@ -360,6 +374,9 @@ func genProc(P *mir.Program, proc *mir.Procedure) *fasmProc {
 			bin(Sub, "rsp", strconv.FormatInt(int64(stackReserve), 10)),
 		},
 	}
+	if proc.Span != nil {
+		init.code = append([]*amd64Instr{comment(proc.Span.String())}, init.code...)
+	}
 	fproc := &fasmProc{label: proc.Label, blocks: []*fasmBlock{init}}
 	proc.ResetBlocks()
 	fproc.blocks = append(fproc.blocks, genBlocks(P, proc, proc.FirstBlock())...)
@@ -382,6 +399,9 @@ func genFalseBranches(P *mir.Program, proc *mir.Procedure, block *mir.BasicBlock
 	}
 	block.Visited = true
 	fb := genCode(P, proc, block)
+	if block.Out.Span != nil {
+		fb.code = append(fb.code, comment(block.Out.Span.String()))
+	}
 
 	// should generate Jmp only for true branches and Jmps that point to
 	// already visited blocks
@@ -416,7 +436,7 @@ func genFalseBranches(P *mir.Program, proc *mir.Procedure, block *mir.BasicBlock
 		fb.code = append(fb.code, ret...)
 		return []*fasmBlock{fb}
 	}
-	panic("Invalid flow: " + block.Out.String())
+	panic(block.Out.Span.String() + ": invalid flow: " + block.Out.String())
 }
 
 func genCondJmp(P *mir.Program, proc *mir.Procedure, block *mir.BasicBlock, op mir.Operand) []*amd64Instr {
@@ -455,7 +475,14 @@ func genExit(P *mir.Program, proc *mir.Procedure, op mir.Operand) []*amd64Instr
 
 func genCode(P *mir.Program, proc *mir.Procedure, block *mir.BasicBlock) *fasmBlock {
 	output := make([]*amd64Instr, len(block.Code))[:0]
+	var last *span.Span
 	for _, instr := range block.Code {
+		// resalloc expands a single PIR instruction into many,
+		// so we only comment when the span changes
+		if instr.Span != nil && (last == nil || *instr.Span != *last) {
+			output = append(output, comment(instr.Span.String()))
+			last = instr.Span
+		}
 		output = append(output, genInstr(P, proc, instr)...)
 	}
 	return &fasmBlock{label: block.Label, code: output}
@@ -488,16 +515,38 @@ func genInstr(P *mir.Program, proc *mir.Procedure, instr mir.Instr) []*amd64Inst
 	case IT.Call:
 		return genCall(P, proc, instr)
 	default:
-		panic("unimplemented: " + instr.String())
+		panic(instr.Span.String() + ": unimplemented: " + instr.String())
 	}
 }
 
 // uint -> int and int -> uint are "converted" xD
+// widening sign extends signed types and zero extends the rest,
+// literals are already extended
 func genConvert(P *mir.Program, proc *mir.Procedure, instr mir.Instr) []*amd64Instr {
 	out, newA := resolveOperand(P, proc, instr.A.Operand)
 	newDest := convertOptOperandProc(P, proc, instr.Dest)
-	if instr.Dest.Type.Size() > instr.A.Type.Size() {
-		out = append(out, bin(Movsx, newDest, newA))
+	if instr.Dest.Type.Size() > instr.A.Type.Size() && instr.A.Class != mirc.Lit {
+		// extensions only write to registers, so anything else
+		// is extended in rax and then stored
+		reg := RAX
+		if instr.Dest.Class == mirc.Register {
+			reg = Registers[instr.Dest.Num]
+		}
+		switch {
+		case T.IsInt(instr.A.Type) && instr.A.Type.Size() == 4:
+			out = append(out, bin(Movsxd, genReg(reg, instr.Dest.Type), newA))
+		case T.IsInt(instr.A.Type):
+			out = append(out, bin(Movsx, genReg(reg, instr.Dest.Type), newA))
+		case instr.A.Type.Size() == 4:
+			// there's no movzx from 32 bits, writing the lower half
+			// of a register already clears the upper half
+			out = append(out, bin(Mov, genReg(reg, instr.A.Type), newA))
+		default:
+			out = append(out, bin(Movzx, genReg(reg, instr.Dest.Type), newA))
+		}
+		if instr.Dest.Class != mirc.Register {
+			out = append(out, bin(Mov, newDest, genReg(reg, instr.Dest.Type)))
+		}
 		return out
 	}
 	if instr.A.Class == mirc.Lit {
@@ -605,22 +654,22 @@ func genDiv(P *mir.Program, proc *mir.Procedure, instr mir.Instr) []*amd64Instr
 	newOp1 := convertOptOperandProc(P, proc, instr.A)
 	newOp2 := convertOptOperandProc(P, proc, instr.B)
 	newDest := convertOptOperandProc(P, proc, instr.Dest)
+	out := []*amd64Instr{
+		mov(genReg(RAX, instr.Type), newOp1),
+		extendDividend(instr.Type),
+	}
 	if instr.B.Class == mirc.Lit || instr.B.Class == mirc.Static {
 		rbx := genReg(RBX, instr.Type)
-		return []*amd64Instr{
-			bin(Xor, RDX.QWord, RDX.QWord),
-			mov(genReg(RAX, instr.Type), newOp1),
+		return append(out, []*amd64Instr{
 			mov(rbx, newOp2),
 			unary(instrName, rbx),
 			mov(newDest, genReg(RAX, instr.Type)),
-		}
+		}...)
 	}
-	return []*amd64Instr{
-		bin(Xor, RDX.QWord, RDX.QWord),
-		mov(genReg(RAX, instr.Type), newOp1),
+	return append(out, []*amd64Instr{
 		unary(instrName, newOp2),
 		mov(newDest, genReg(RAX, instr.Type)),
-	}
+	}...)
 }
 
 func genRem(P *mir.Program, proc *mir.Procedure, instr mir.Instr) []*amd64Instr {
@@ -629,22 +678,50 @@ func genRem(P *mir.Program, proc *mir.Procedure, instr mir.Instr) []*amd64Instr
 	newOp1 := convertOptOperandProc(P, proc, instr.A)
 	newOp2 := convertOptOperandProc(P, proc, instr.B)
 	newDest := convertOptOperandProc(P, proc, instr.Dest)
+	out := []*amd64Instr{
+		mov(genReg(RAX, instr.Type), newOp1),
+		extendDividend(instr.Type),
+	}
 	if mirc.IsImmediate(instr.B.Class) {
 		rbx := genReg(RBX, instr.Type)
-		return []*amd64Instr{
-			bin(Xor, RDX.QWord, RDX.QWord),
-			mov(genReg(RAX, instr.Type), newOp1),
+		out = append(out, []*amd64Instr{
 			mov(rbx, newOp2),
 			unary(instrName, rbx),
-			mov(newDest, genReg(RDX, instr.Type)),
+		}...)
+	} else {
+		out = append(out, unary(instrName, newOp2))
+	}
+	// byte divisions leave the remainder in ah, which can't be
+	// used together with the registers that need a REX prefix
+	if instr.Type.Size() == 1 {
+		return append(out, []*amd64Instr{
+			mov(RAX.Byte, "ah"),
+			mov(newDest, RAX.Byte),
+		}...)
+	}
+	return append(out, mov(newDest, genReg(RDX, instr.Type)))
+}
+
+// the dividend of div and idiv is twice the size of the divisor,
+// the upper half sits in rdx (or ah for bytes) and must be
+// sign extended for idiv and zeroed for div
+func extendDividend(t *T.Type) *amd64Instr {
+	if T.IsInt(t) {
+		switch t.Size() {
+		case 1:
+			return &amd64Instr{Instr: Cbw}
+		case 2:
+			return &amd64Instr{Instr: Cwd}
+		case 4:
+			return &amd64Instr{Instr: Cdq}
+		default:
+			return &amd64Instr{Instr: Cqo}
 		}
 	}
-	return []*amd64Instr{
-		bin(Xor, RDX.QWord, RDX.QWord),
-		mov(genReg(RAX, instr.Type), newOp1),
-		unary(instrName, newOp2),
-		mov(newDest, genReg(RDX, instr.Type)),
+	if t.Size() == 1 {
+		return bin(Movzx, RAX.Word, RAX.Byte)
 	}
+	return bin(Xor, RDX.QWord, RDX.QWord)
 }
 
 func genSub(P *mir.Program, proc *mir.Procedure, instr mir.Instr) []*amd64Instr {
@@ -712,6 +789,12 @@ func unary(instr string, op string) *amd64Instr {
 	}
 }
 
+func comment(text string) *amd64Instr {
+	return &amd64Instr{
+		Comment: text,
+	}
+}
+
 func bin(instr string, dest, source string) *amd64Instr {
 	return &amd64Instr{
 		Instr: instr,
@@ -787,6 +870,10 @@ func convertOperand(P *mir.Program, op mir.Operand, NumOfVars, NumOfSpills, NumO
 			(NumOfMaxCalleeArguments-1-op.Num)*8
 		return genType(op.Type) + "[rbp - " + strconv.FormatUint(offset, 10) + "]"
 	case mirc.Lit:
+		// signed literals are sign extended to 64 bits
+		if op.Type != nil && T.IsInt(op.Type) {
+			return strconv.FormatInt(int64(op.Num), 10)
+		}
 		return strconv.FormatUint(op.Num, 10)
 	case mirc.Static:
 		sy := P.Symbols[op.Num]
@@ -852,11 +939,9 @@ func genInstrName(instr mir.Instr) string {
 	case IT.Sub:
 		return Sub
 	case IT.Mult:
-		if T.IsInt(instr.Type) {
-			return IMul
-		} else {
-			return Mul
-		}
+		// mul has no two operand form, and the lower half
+		// of the product is the same for signed and unsigned
+		return IMul
 	case IT.Div, IT.Rem:
 		if T.IsInt(instr.Type) {
 			return IDiv
@@ -872,11 +957,28 @@ func genInstrName(instr mir.Instr) string {
 	case IT.ShiftLeft:
 		return Sal
 	case IT.ShiftRight:
-		return Sar
+		if T.IsInt(instr.Type) {
+			return Sar
+		}
+		return Shr
 	case IT.Eq:
 		return Sete
 	case IT.Diff:
 		return Setne
+	}
+	if !T.IsInt(instr.Type) {
+		switch instr.T {
+		case IT.Less:
+			return Setb
+		case IT.More:
+			return Seta
+		case IT.MoreEq:
+			return Setae
+		case IT.LessEq:
+			return Setbe
+		}
+	}
+	switch instr.T {
 	case IT.Less:
 		return Setl
 	case IT.More:
@@ -899,10 +1001,19 @@ but for now it suffices to resolve it here.
 */
 func resolveOperand(P *mir.Program, proc *mir.Procedure, op mir.Operand) ([]*amd64Instr, string) {
 	opstr := convertOperandProc(P, proc, op)
-	if op.Class == mirc.Lit && op.Num > (1<<31) {
+	if op.Class == mirc.Lit && !fitsImm32(op) {
 		out := genReg(RBX, op.Type)
 		mv := mov(out, opstr)
 		return []*amd64Instr{mv}, out
 	}
 	return nil, opstr
 }
+
+// imm32 operands are sign extended by the cpu
+func fitsImm32(op mir.Operand) bool {
+	if op.Type != nil && T.IsInt(op.Type) {
+		v := int64(op.Num)
+		return v >= -(1<<31) && v < (1<<31)
+	}
+	return op.Num < (1 << 31)
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/padeir0/pir/backends/linuxamd64/fasm"
 	"github.com/padeir0/pir/backends/linuxamd64/resalloc"
 	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	"github.com/padeir0/pir/pass"
 
 	mirchecker "github.com/padeir0/pir/backends/linuxamd64/mir/checker"
 	pirchecker "github.com/padeir0/pir/checker"
@@ -15,11 +17,36 @@ func GenerateFasm(p *pir.Program) (string, *Error) {
 	if err != nil {
 		return "", err
 	}
+	for _, sy := range p.Symbols {
+		if sy.Extern {
+			return "", unresolvedExtern(sy)
+		}
+	}
 	mirProgram := resalloc.Allocate(p, len(fasm.Registers))
 	err = mirchecker.Check(mirProgram)
 	if err != nil {
 		return "", err
 	}
-	fasmProgram := fasm.Generate(mirProgram)
+	fasmProgram := fasm.Generate(mirProgram, "")
 	return fasmProgram.Contents, nil
 }
+
+// GenerateFasmWith runs the pipeline over p before generating code,
+// see pass.Parse. The program is modified in place.
+func GenerateFasmWith(p *pir.Program, pipeline string) (string, *Error) {
+	err := pass.New(p).RunPipeline(pipeline)
+	if err != nil {
+		return "", err
+	}
+	return GenerateFasm(p)
+}
+
+func unresolvedExtern(sy *pir.Symbol) *Error {
+	label := ""
+	if sy.Proc != nil {
+		label = sy.Proc.Label
+	} else if sy.Mem != nil {
+		label = sy.Mem.Label
+	}
+	return eu.NewLinkError("unresolved extern symbol: " + label)
+}
@@ -6,27 +6,64 @@ import (
 	FT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
 	IT "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
 	. "github.com/padeir0/pir/errors"
+	EC "github.com/padeir0/pir/errors/code"
 	eu "github.com/padeir0/pir/errors/util"
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
 
 	"strconv"
 	"strings"
 )
 
+// Check returns the first diagnostic found in P, or nil.
 func Check(P *mir.Program) *Error {
+	diags := check(P, false)
+	if len(diags) == 0 {
+		return nil
+	}
+	return diags[0]
+}
+
+// CheckAll checks every procedure of P and returns all diagnostics found,
+// instead of stopping at the first one. Since a bad instruction doesn't
+// update the simulated state, later diagnostics may follow from earlier ones.
+func CheckAll(P *mir.Program) []*Error {
+	return check(P, true)
+}
+
+func check(P *mir.Program, collect bool) []*Error {
+	r := &reporter{collect: collect}
 	for _, sy := range P.Symbols {
 		if sy.Proc != nil && !sy.Builtin {
 			s := newState(P)
+			s.r = r
 			s.proc = sy.Proc
 			s.proc.ResetBlocks()
 			s.Init()
-			err := checkCode(s, sy.Proc.FirstBlock())
+			err := checkCode(s, sy.Proc.Start)
 			if err != nil {
-				return err
+				return r.diags
 			}
 		}
 	}
-	return nil
+	return r.diags
+}
+
+type reporter struct {
+	collect bool
+	diags   []*Error
+}
+
+// Report records err and returns it if checking should stop
+func (r *reporter) Report(err *Error) *Error {
+	if err == nil {
+		return nil
+	}
+	r.diags = append(r.diags, err)
+	if r.collect {
+		return nil
+	}
+	return err
 }
 
 type region []mir.OptOperand
@@ -71,6 +108,8 @@ type state struct {
 	Program *mir.Program
 	proc    *mir.Procedure
 	bb      *mir.BasicBlock
+	id      mir.BlockID
+	r       *reporter
 
 	CalleeInterproc region
 	CallerInterproc region
@@ -82,6 +121,7 @@ type state struct {
 func newState(M *mir.Program) *state {
 	return &state{
 		Program:         M,
+		r:               &reporter{},
 		CalleeInterproc: newRegion(8),
 		CallerInterproc: newRegion(8),
 		Spill:           newRegion(8),
@@ -128,6 +168,8 @@ func (s *state) Copy() *state {
 		Registers:       registers,
 		Locals:          locals,
 		bb:              s.bb,
+		id:              s.id,
+		r:               s.r,
 		Program:         s.Program,
 		proc:            s.proc,
 	}
@@ -156,15 +198,33 @@ func newLocalOperand(t *T.Type, i uint64) mir.Operand {
 	}
 }
 
-func checkCode(s *state, bb *mir.BasicBlock) *Error {
+// fills the location of err with the current block,
+// index is -1 for the flow
+func (s *state) locate(err *Error, index int, sp *span.Span) *Error {
+	err.Proc = s.proc.Label
+	err.Block = int(s.id)
+	err.Index = index
+	return eu.WithSpan(spanOf(s, sp), err)
+}
+
+func checkCode(s *state, id mir.BlockID) *Error {
+	bb := s.proc.GetBlock(id)
 	if bb.Visited {
 		return nil
 	}
 	s.bb = bb
-	for _, instr := range bb.Code {
-		err := checkInstr(s, instr)
+	s.id = id
+	for i := range bb.Code {
+		instr := &bb.Code[i]
+		err := checkInstr(s, *instr)
 		if err != nil {
-			return err
+			if err.Instr == nil {
+				err.Instr = instr
+			}
+			err = s.r.Report(s.locate(err, i, instr.Span))
+			if err != nil {
+				return err
+			}
 		}
 	}
 	bb.Visited = true
@@ -175,31 +235,41 @@ func checkJump(s *state) *Error {
 	bb := s.bb
 	switch bb.Out.T {
 	case FT.Jmp:
-		t := s.proc.GetBlock(bb.Out.True)
-		return checkCode(s, t)
+		return checkCode(s, bb.Out.True)
 	case FT.If:
 		s2 := s.Copy()
-		t := s.proc.GetBlock(bb.Out.True)
-		err := checkCode(s, t)
+		err := checkCode(s, bb.Out.True)
 		if err != nil {
 			return err
 		}
-		f := s.proc.GetBlock(bb.Out.False)
-		return checkCode(s2, f)
+		return checkCode(s2, bb.Out.False)
 	case FT.Return:
-		return checkRet(s)
+		err := checkRet(s)
+		if err == nil {
+			return nil
+		}
+		err.Instr = &bb.Out
+		return s.r.Report(s.locate(err, -1, bb.Out.Span))
 	}
 	return nil
 }
 
+// falls back to the span of the procedure
+func spanOf(s *state, sp *span.Span) *span.Span {
+	if sp == nil {
+		return s.proc.Span
+	}
+	return sp
+}
+
 func checkRet(s *state) *Error {
 	for i, ret := range s.proc.Rets {
 		op, ok := s.CallerInterproc.Load(uint64(i))
 		if !ok {
-			return eu.NewInternalSemanticError("return stack is empty, expected returns: " + s.proc.StrRets())
+			return eu.NewCheckError(EC.ReturnStackEmpty, nil, nil, "return stack is empty, expected returns: "+s.proc.StrRets())
 		}
 		if !ret.Equals(op.Type) {
-			return eu.NewInternalSemanticError("return of type " + ret.String() + " doesn't match value in stack: " + s.CallerInterproc.String())
+			return eu.NewCheckError(EC.ReturnBadType, nil, &op, "return of type "+ret.String()+" doesn't match value in stack: "+s.CallerInterproc.String())
 		}
 		s.CallerInterproc.Clear(i)
 	}
@@ -715,47 +785,47 @@ func checkForm(instr mir.Instr, hasA, hasB, hasDest bool) *Error {
 }
 
 func malformedInstr(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("malformed instruction: " + instr.String())
+	return eu.NewCheckError(EC.MalformedInstr, &instr, nil, "malformed instruction: "+instr.String())
 }
 func malformedEqualTypes(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("unequal types: " + instr.String())
+	return eu.NewCheckError(EC.UnequalTypes, &instr, nil, "unequal types: "+instr.String())
 }
 func malformedTypeOrClass(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("malformed type or class: " + instr.String())
+	return eu.NewCheckError(EC.MalformedTypeOrClass, &instr, nil, "malformed type or class: "+instr.String())
 }
 func invalidClass(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("invalid class: " + instr.String())
+	return eu.NewCheckError(EC.InvalidClass, &instr, nil, "invalid class: "+instr.String())
 }
 func errorLoadingGarbage(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("loading garbage: " + instr.String())
+	return eu.NewCheckError(EC.LoadingGarbage, &instr, &instr.A.Operand, "loading garbage: "+instr.String())
 }
 func errorCallLoadingGarbage(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("call loading garbage: " + instr.String())
+	return eu.NewCheckError(EC.LoadingGarbage, &instr, nil, "call loading garbage: "+instr.String())
 }
 func errorUsingRegisterGarbage(instr mir.Instr, op mir.Operand) *Error {
-	return eu.NewInternalSemanticError("using register garbage: " + op.String() + " of " + instr.String())
+	return eu.NewCheckError(EC.RegisterGarbage, &instr, &op, "using register garbage: "+op.String()+" of "+instr.String())
 }
 func errorIncorrectValueInRegister(instr mir.Instr, o, op mir.Operand) *Error {
-	return eu.NewInternalSemanticError("incorrect value in register (" + o.String() + "): " + op.String() + " of " + instr.String())
+	return eu.NewCheckError(EC.IncorrectValueInRegister, &instr, &op, "incorrect value in register ("+o.String()+"): "+op.String()+" of "+instr.String())
 }
 func errorLoadingIncorrectType(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("load of incorrect type: " + instr.String())
+	return eu.NewCheckError(EC.LoadingIncorrectType, &instr, nil, "load of incorrect type: "+instr.String())
 }
 func procArgNotFound(instr mir.Instr, p *mir.Procedure) *Error {
-	return eu.NewInternalSemanticError("argument " + p.Label + " not found in: " + instr.String())
+	return eu.NewCheckError(EC.CallBadArg, &instr, nil, "argument "+p.Label+" not found in: "+instr.String())
 }
 func procBadArg(instr mir.Instr, d *T.Type, op mir.Operand) *Error {
-	return eu.NewInternalSemanticError("argument " + op.String() + " doesn't match formal parameter (" + d.String() + ") in: " + instr.String())
+	return eu.NewCheckError(EC.CallBadArg, &instr, &op, "argument "+op.String()+" doesn't match formal parameter ("+d.String()+") in: "+instr.String())
 }
 func procBadRet(instr mir.Instr, d T.Type, op mir.Operand) *Error {
-	return eu.NewInternalSemanticError("return " + op.String() + " doesn't match formal return " + d.String() + " in: " + instr.String())
+	return eu.NewCheckError(EC.CallBadRet, &instr, &op, "return "+op.String()+" doesn't match formal return "+d.String()+" in: "+instr.String())
 }
 
 func nilInstr(s *state) *Error {
-	return eu.NewInternalSemanticError("nil instruction in: " + s.proc.Label + " " + s.bb.Label)
+	return eu.NewCheckError(EC.NilInstr, nil, nil, "nil instruction in: "+s.proc.Label+" "+s.bb.Label)
 }
 func notAProc(instr mir.Instr) *Error {
-	return eu.NewInternalSemanticError("not a procedure: " + instr.String())
+	return eu.NewCheckError(EC.ExpectedProc, &instr, nil, "not a procedure: "+instr.String())
 }
 func symbolNotFound(i int64) *Error {
 	return eu.NewInternalSemanticError("Symbol not found in program: " + strconv.FormatInt(i, 10))
@@ -4,6 +4,7 @@ import (
 	mirc "github.com/padeir0/pir/backends/linuxamd64/mir/class"
 	FT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
 	IT "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
 	"strconv"
 	"strings"
@@ -86,6 +87,8 @@ type Procedure struct {
 	Start     BlockID
 	AllBlocks []*BasicBlock
 
+	Span *span.Span // optional
+
 	NumOfVars               int
 	NumOfSpills             int
 	NumOfMaxCalleeArguments int
@@ -96,6 +99,7 @@ func (this *Procedure) String() string {
 	output += this.StrArgs() + "\n"
 	output += this.StrRets() + "\n"
 	output += this.StrLocals() + "\n"
+	output += this.StrFrame() + "\n"
 	output += "}:\n"
 	for _, bb := range this.AllBlocks {
 		output += bb.String() + "\n"
@@ -112,6 +116,11 @@ func (p *Procedure) StrArgs() string {
 func (p *Procedure) StrLocals() string {
 	return StrTypes(p.Vars)
 }
+func (p *Procedure) StrFrame() string {
+	return "vars " + strconv.Itoa(p.NumOfVars) +
+		", spills " + strconv.Itoa(p.NumOfSpills) +
+		", callee " + strconv.Itoa(p.NumOfMaxCalleeArguments)
+}
 
 func (this *Procedure) FirstBlock() *BasicBlock {
 	return this.AllBlocks[this.Start]
@@ -135,7 +144,7 @@ func StrTypes(tps []*T.Type) string {
 		return tps[0].String()
 	}
 	output := tps[0].String()
-	for _, t := range tps {
+	for _, t := range tps[1:] {
 		output += ", " + t.String()
 	}
 	return output
@@ -208,6 +217,8 @@ type Flow struct {
 	V     []Operand
 	True  BlockID
 	False BlockID
+
+	Span *span.Span // optional
 }
 
 func (this *Flow) String() string {
@@ -260,7 +271,10 @@ func (o *Operand) String() string {
 	value := strconv.FormatUint(o.Num, 10)
 	switch o.Class {
 	case mirc.Lit:
-		return value
+		if o.Type != nil && T.IsInt(o.Type) {
+			value = strconv.FormatInt(int64(o.Num), 10)
+		}
+		return value + ":" + o.Type.String()
 	case mirc.Local:
 		return "local#" + value + ":" + o.Type.String()
 	case mirc.Spill:
@@ -268,7 +282,7 @@ func (o *Operand) String() string {
 	case mirc.Register:
 		return "r" + value + ":" + o.Type.String()
 	case mirc.Static:
-		return "globall#" + value + ":" + o.Type.String()
+		return "static#" + value + ":" + o.Type.String()
 	case mirc.CallerInterproc:
 		return "caller#" + value + ":" + o.Type.String()
 	case mirc.CalleeInterproc:
@@ -283,6 +297,8 @@ type Instr struct {
 	A    OptOperand
 	B    OptOperand
 	Dest OptOperand
+
+	Span *span.Span // optional, carried over from PIR
 }
 
 func (this *Instr) String() string {
@@ -300,7 +316,7 @@ func (this *Instr) String() string {
 		}
 	} else {
 		if this.B.Valid {
-			output += " ???, " + this.B.String()
+			output += " ?, " + this.B.String()
 		}
 	}
 	if this.Dest.Valid {
@@ -0,0 +1,517 @@
+package parse
+
+import (
+	"github.com/padeir0/pir/backends/linuxamd64/mir"
+	mirc "github.com/padeir0/pir/backends/linuxamd64/mir/class"
+	FT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
+	IT "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	"github.com/padeir0/pir/parse/common"
+	lk "github.com/padeir0/pir/parse/lexer"
+	T "github.com/padeir0/pir/types"
+
+	"strconv"
+	"strings"
+)
+
+// Program parses the text printed by mir.Program.String() back into
+// a program, this way backend tests don't need to go through resalloc.
+//
+// Every procedure starts at its first block and the entry point
+// is the symbol labeled "main", if there's one. The symbol indexes
+// ([0], [1], ...) are optional, but if present they must be in order.
+//
+// The frame line (vars N, spills N, callee N) is optional, if it's missing,
+// the number of variables is the number of locals and the rest is zero.
+// Literals without a type take the type of the instruction.
+func Program(input string) (*mir.Program, *Error) {
+	name, rest, line := common.Header(input)
+	tokens, err := lk.Lex(rest, line)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		Parser:  common.NewParser(tokens),
+		program: &mir.Program{Name: name, Symbols: []*mir.Symbol{}},
+	}
+	err = parseSymbols(p)
+	if err != nil {
+		return nil, err
+	}
+	for i, sy := range p.program.Symbols {
+		if sy.Proc != nil && sy.Proc.Label == "main" {
+			p.program.Entry = mir.SymbolID(i)
+			break
+		}
+	}
+	return p.program, nil
+}
+
+type parser struct {
+	*common.Parser
+
+	program *mir.Program
+}
+
+// Symbols := {['[' number ']'] Symbol | NL} EOF
+func parseSymbols(p *parser) *Error {
+	for {
+		p.SkipNewlines()
+		if p.Is(lk.EOF) {
+			return nil
+		}
+		if p.Is(lk.LeftBracket) {
+			err := parseIndex(p)
+			if err != nil {
+				return err
+			}
+		}
+		err := parseSymbol(p)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func parseIndex(p *parser) *Error {
+	p.Next() // '['
+	tk, err := p.Expect(lk.Number)
+	if err != nil {
+		return err
+	}
+	index, err := common.Num(tk)
+	if err != nil {
+		return err
+	}
+	if index != uint64(len(p.program.Symbols)) {
+		return eu.NewParseError(tk.Line, tk.Col, "symbol index out of order, expected "+strconv.Itoa(len(p.program.Symbols)))
+	}
+	_, err = p.Expect(lk.RightBracket)
+	return err
+}
+
+// Symbol := Procedure | label ':' ('builtin' | number | string)
+func parseSymbol(p *parser) *Error {
+	label, err := p.Expect(lk.Ident)
+	if err != nil {
+		return err
+	}
+	if p.Is(lk.LeftBrace) {
+		return parseProc(p, label)
+	}
+	_, err = p.Expect(lk.Colon)
+	if err != nil {
+		return err
+	}
+	tk := p.Next()
+	switch tk.Kind {
+	case lk.Ident:
+		if tk.Text != "builtin" {
+			return common.Expected(tk, "'builtin'")
+		}
+		sy := &mir.Symbol{Proc: &mir.Procedure{Label: label.Text}, Builtin: true}
+		p.program.Symbols = append(p.program.Symbols, sy)
+	case lk.Number:
+		size, err := common.Num(tk)
+		if err != nil {
+			return err
+		}
+		p.program.AddMem(&mir.MemoryDecl{Label: label.Text, Size: size})
+	case lk.String:
+		p.program.AddMem(&mir.MemoryDecl{Label: label.Text, Data: tk.Text})
+	default:
+		return common.Expected(tk, "'builtin', number or string")
+	}
+	return p.ExpectEOL()
+}
+
+// Procedure := label '{' NL Types NL Types NL Types NL [Frame NL] '}' ':' NL {Block}
+func parseProc(p *parser, label lk.Token) *Error {
+	p.Next() // '{'
+	_, err := p.Expect(lk.Newline)
+	if err != nil {
+		return err
+	}
+	proc := &mir.Procedure{
+		Label:     label.Text,
+		Start:     0,
+		AllBlocks: []*mir.BasicBlock{},
+	}
+
+	proc.Args, err = p.TypeLine()
+	if err != nil {
+		return err
+	}
+	proc.Rets, err = p.TypeLine()
+	if err != nil {
+		return err
+	}
+	proc.Vars, err = p.TypeLine()
+	if err != nil {
+		return err
+	}
+	proc.NumOfVars = len(proc.Vars)
+	if !p.Is(lk.RightBrace) {
+		err = parseFrame(p, proc)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = p.Expect(lk.RightBrace)
+	if err != nil {
+		return err
+	}
+	_, err = p.Expect(lk.Colon)
+	if err != nil {
+		return err
+	}
+	err = p.ExpectEOL()
+	if err != nil {
+		return err
+	}
+
+	for {
+		p.SkipNewlines()
+		if !isBlockStart(p) {
+			break
+		}
+		bb, err := parseBlock(p)
+		if err != nil {
+			return err
+		}
+		proc.AllBlocks = append(proc.AllBlocks, bb)
+	}
+	p.program.AddProc(proc)
+	return nil
+}
+
+// Frame := 'vars' number ',' 'spills' number ',' 'callee' number
+func parseFrame(p *parser, proc *mir.Procedure) *Error {
+	fields := []struct {
+		name  string
+		value *int
+	}{
+		{"vars", &proc.NumOfVars},
+		{"spills", &proc.NumOfSpills},
+		{"callee", &proc.NumOfMaxCalleeArguments},
+	}
+	for i, field := range fields {
+		if i > 0 {
+			_, err := p.Expect(lk.Comma)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := p.ExpectKeyword(field.name)
+		if err != nil {
+			return err
+		}
+		tk, err := p.Expect(lk.Number)
+		if err != nil {
+			return err
+		}
+		num, err := common.Num(tk)
+		if err != nil {
+			return err
+		}
+		*field.value = int(num)
+	}
+	_, err := p.Expect(lk.Newline)
+	return err
+}
+
+// a block starts with 'label:' alone in a line,
+// memory declarations and builtins always have something after the colon
+func isBlockStart(p *parser) bool {
+	if p.Peek().Kind != lk.Ident || p.PeekAt(1).Kind != lk.Colon {
+		return false
+	}
+	next := p.PeekAt(2).Kind
+	return next == lk.Newline || next == lk.EOF
+}
+
+// Block := label ':' NL {Instr NL} Flow EOL
+func parseBlock(p *parser) (*mir.BasicBlock, *Error) {
+	label := p.Next()
+	p.Next() // ':'
+	p.Next() // NL
+	bb := &mir.BasicBlock{
+		Label: label.Text,
+		Code:  []mir.Instr{},
+	}
+	for {
+		p.SkipNewlines()
+		if isFlowStart(p) {
+			break
+		}
+		instr, err := parseInstr(p)
+		if err != nil {
+			return nil, err
+		}
+		bb.AddInstr(instr)
+		err = p.ExpectEOL()
+		if err != nil {
+			return nil, err
+		}
+	}
+	flow, err := parseFlow(p)
+	if err != nil {
+		return nil, err
+	}
+	bb.Out = flow
+	return bb, p.ExpectEOL()
+}
+
+func isFlowStart(p *parser) bool {
+	tk := p.Peek()
+	if tk.Kind != lk.Ident {
+		return tk.Kind == lk.EOF
+	}
+	switch tk.Text {
+	case "jmp", "if", "ret", "exit", "invalid":
+		return true
+	}
+	return false
+}
+
+// Flow := 'jmp' blockid | 'if' Operand '?' blockid ':' blockid
+// Flow := 'ret' [Operands] | 'exit' Operand | 'invalid' 'FlowType'
+func parseFlow(p *parser) (mir.Flow, *Error) {
+	tk := p.Peek()
+	if tk.Kind != lk.Ident {
+		return mir.Flow{}, common.Expected(tk, "flow")
+	}
+	p.Next()
+	switch tk.Text {
+	case "jmp":
+		id, err := parseBlockID(p)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		return mir.Flow{T: FT.Jmp, True: id}, nil
+	case "if":
+		cond, err := parseOperand(p, T.T_Bool)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		_, err = p.Expect(lk.Question)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		t, err := parseBlockID(p)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		_, err = p.Expect(lk.Colon)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		f, err := parseBlockID(p)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		return mir.Flow{T: FT.If, V: []mir.Operand{cond}, True: t, False: f}, nil
+	case "ret":
+		rets := []mir.Operand{}
+		for !p.IsEOL() {
+			if len(rets) > 0 {
+				_, err := p.Expect(lk.Comma)
+				if err != nil {
+					return mir.Flow{}, err
+				}
+			}
+			op, err := parseOperand(p, nil)
+			if err != nil {
+				return mir.Flow{}, err
+			}
+			rets = append(rets, op)
+		}
+		return mir.Flow{T: FT.Return, V: rets}, nil
+	case "exit":
+		op, err := parseOperand(p, T.T_I8)
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		return mir.Flow{T: FT.Exit, V: []mir.Operand{op}}, nil
+	case "invalid":
+		_, err := p.ExpectKeyword("FlowType")
+		if err != nil {
+			return mir.Flow{}, err
+		}
+		return mir.Flow{T: FT.InvalidFlow}, nil
+	}
+	return mir.Flow{}, common.Expected(tk, "flow")
+}
+
+// blockid := '.L' number
+func parseBlockID(p *parser) (mir.BlockID, *Error) {
+	tk, err := p.Expect(lk.Ident)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(tk.Text, ".L") {
+		return 0, common.Expected(tk, "block id (.L<number>)")
+	}
+	id, convErr := strconv.ParseInt(tk.Text[2:], 10, 64)
+	if convErr != nil || id < 0 {
+		return 0, common.Expected(tk, "block id (.L<number>)")
+	}
+	return mir.BlockID(id), nil
+}
+
+// Instr := kind [':' Type] [(Operand | '?') [[','] Operand]] ['->' Operand]
+func parseInstr(p *parser) (mir.Instr, *Error) {
+	start, err := p.Expect(lk.Ident)
+	if err != nil {
+		return mir.Instr{}, err
+	}
+	kind, ok := instrKinds[start.Text]
+	if !ok {
+		return mir.Instr{}, common.Expected(start, "instruction")
+	}
+	instr := mir.Instr{T: kind}
+	if p.Is(lk.Colon) {
+		p.Next()
+		instr.Type, err = p.Type()
+		if err != nil {
+			return mir.Instr{}, err
+		}
+	}
+	litType := literalType(instr)
+	if !p.IsEOL() && !p.Is(lk.Arrow) {
+		if p.Is(lk.Question) {
+			// only B is present, see mir.Instr.String
+			p.Next()
+		} else {
+			a, err := parseOperand(p, litType)
+			if err != nil {
+				return mir.Instr{}, err
+			}
+			instr.A = mir.OptOperand_(a)
+		}
+		if p.Is(lk.Comma) {
+			p.Next()
+		}
+		if !p.IsEOL() && !p.Is(lk.Arrow) {
+			b, err := parseOperand(p, litType)
+			if err != nil {
+				return mir.Instr{}, err
+			}
+			instr.B = mir.OptOperand_(b)
+		}
+	}
+	if p.Is(lk.Arrow) {
+		p.Next()
+		dest, err := parseOperand(p, litType)
+		if err != nil {
+			return mir.Instr{}, err
+		}
+		instr.Dest = mir.OptOperand_(dest)
+	}
+	return instr, nil
+}
+
+func literalType(instr mir.Instr) *T.Type {
+	switch instr.T {
+	case IT.Convert, IT.Call:
+		return nil
+	case IT.LoadPtr:
+		return T.T_Ptr
+	}
+	return instr.Type
+}
+
+var instrKinds = map[string]IT.InstrKind{}
+
+func init() {
+	for k := IT.Add; k <= IT.Call; k++ {
+		instrKinds[k.String()] = k
+	}
+}
+
+// Operand := register | class '#' number ':' Type | number [':' Type]
+// register := 'r' number ':' Type
+// class := 'spill' | 'caller' | 'callee' | 'local' | 'static'
+//
+// untyped literals take the litType, if it's not nil
+func parseOperand(p *parser, litType *T.Type) (mir.Operand, *Error) {
+	tk := p.Next()
+	switch tk.Kind {
+	case lk.Number:
+		num, err := common.Lit(tk)
+		if err != nil {
+			return mir.Operand{}, err
+		}
+		op := mir.Operand{Class: mirc.Lit, Num: num, Type: litType}
+		if p.Is(lk.Colon) {
+			p.Next()
+			op.Type, err = p.Type()
+			if err != nil {
+				return mir.Operand{}, err
+			}
+		}
+		if op.Type == nil {
+			return mir.Operand{}, eu.NewParseError(tk.Line, tk.Col, "can't infer literal type, annotate it (ex: 1:i64)")
+		}
+		return op, nil
+	case lk.Ident:
+		if isRegister(tk.Text) {
+			num, err := strconv.ParseUint(tk.Text[1:], 10, 64)
+			if err != nil {
+				return mir.Operand{}, common.Expected(tk, "register")
+			}
+			return parseOperandType(p, mirc.Register, num)
+		}
+		class, ok := operandClasses[tk.Text]
+		if !ok {
+			return mir.Operand{}, common.Expected(tk, "operand")
+		}
+		_, err := p.Expect(lk.Hash)
+		if err != nil {
+			return mir.Operand{}, err
+		}
+		numTk, err := p.Expect(lk.Number)
+		if err != nil {
+			return mir.Operand{}, err
+		}
+		num, err := common.Num(numTk)
+		if err != nil {
+			return mir.Operand{}, err
+		}
+		return parseOperandType(p, class, num)
+	}
+	return mir.Operand{}, common.Expected(tk, "operand")
+}
+
+func parseOperandType(p *parser, class mirc.Class, num uint64) (mir.Operand, *Error) {
+	_, err := p.Expect(lk.Colon)
+	if err != nil {
+		return mir.Operand{}, err
+	}
+	t, err := p.Type()
+	if err != nil {
+		return mir.Operand{}, err
+	}
+	return mir.Operand{Class: class, Num: num, Type: t}, nil
+}
+
+func isRegister(s string) bool {
+	if len(s) < 2 || s[0] != 'r' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var operandClasses = map[string]mirc.Class{
+	"spill":  mirc.Spill,
+	"caller": mirc.CallerInterproc,
+	"callee": mirc.CalleeInterproc,
+	"local":  mirc.Local,
+	"static": mirc.Static,
+}
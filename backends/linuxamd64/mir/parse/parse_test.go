@@ -0,0 +1,147 @@
+package parse
+
+import (
+	"github.com/padeir0/pir/backends/linuxamd64/mir"
+	"github.com/padeir0/pir/backends/linuxamd64/mir/checker"
+	EC "github.com/padeir0/pir/errors/code"
+
+	"strings"
+	"testing"
+)
+
+const sample = `Program: sample
+
+[0]write: builtin
+[1]msg: "hello\n"
+[2]buff: 64
+[3]square{
+i64, i32
+i64
+
+vars 0, spills 0, callee 0
+}:
+square_b0:
+	load:i64 caller#0:i64 -> r0:i64
+	mult:i64 r0:i64 r0:i64 -> r0:i64
+	store:i64 r0:i64 -> caller#0:i64
+	ret
+
+
+[4]main{
+
+
+i64, bool
+vars 2, spills 0, callee 2
+}:
+main_b0:
+	store:i64 5:i64 -> callee#0:i64
+	store:i32 2:i32 -> callee#1:i32
+	call static#3:proc[i64, i32][i64]
+	load:i64 callee#0:i64 -> r0:i64
+	store:i64 r0:i64 -> local#0:i64
+	less:i64 r0:i64 30:i64 -> r0:bool
+	if r0:bool? .L1 : .L2
+main_b1:
+	storeptr:i64 7:i64 static#2:ptr
+	jmp .L2
+main_b2:
+	exit 0:i8
+
+
+`
+
+// no indexes, no frame line and untyped literals
+const short = `Program: short
+
+main{
+
+
+i8
+}:
+b0:
+	copy:i8 -1 -> r0:i8
+	store:i8 r0:i8 -> local#0:i8
+	copy:i8 ?, -5 -> r1:i8
+	exit r0:i8
+`
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		entry mir.SymbolID
+		valid bool
+	}{
+		{"sample", sample, 4, true},
+		{"short", short, 0, false},
+	}
+	for _, tt := range tests {
+		M, err := Program(tt.input)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if M.Entry != tt.entry {
+			t.Errorf("%v: entry is %v, expected %v", tt.name, M.Entry, tt.entry)
+		}
+		first := M.String()
+		N, err := Program(first)
+		if err != nil {
+			t.Fatalf("%v: reparsing: %v", tt.name, err)
+		}
+		if second := N.String(); first != second {
+			t.Errorf("%v: output differs after reparsing:\n%v\n%v", tt.name, first, second)
+		}
+		err = checker.Check(N)
+		if tt.valid && err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%v: expected the checker to fail", tt.name)
+		}
+	}
+}
+
+func TestShort(t *testing.T) {
+	M, err := Program(short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc := M.Symbols[0].Proc
+	if proc.NumOfVars != 1 || proc.NumOfSpills != 0 || proc.NumOfMaxCalleeArguments != 0 {
+		t.Errorf("unexpected frame: vars %v, spills %v, callee %v",
+			proc.NumOfVars, proc.NumOfSpills, proc.NumOfMaxCalleeArguments)
+	}
+	code := proc.AllBlocks[0].Code
+	if code[0].A.Num != 1<<64-1 {
+		t.Errorf("literal is %v, expected %v", code[0].A.Num, uint64(1<<64-1))
+	}
+	if code[2].A.Valid || !code[2].B.Valid || code[2].B.Num != 1<<64-5 {
+		t.Errorf("expected only B in %v", code[2])
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		message string
+	}{
+		{"index out of order", "[1]buff: 64\n", "out of order"},
+		{"bad symbol", "x: extern mem\n", "'builtin'"},
+		{"bad flow", "main{\n\n\n\n}:\nb0:\n\tjmp L1\n", "expected block id"},
+		{"unterminated string", "x: \"abc\n", "unterminated string"},
+	}
+	for _, tt := range tests {
+		_, err := Program(tt.input)
+		if err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+			continue
+		}
+		if err.Code != EC.Parse {
+			t.Errorf("%v: error code is %v, expected %v", tt.name, err.Code, EC.Parse)
+		}
+		if !strings.Contains(err.Message, tt.message) {
+			t.Errorf("%v: error %q doesn't mention %q", tt.name, err.Message, tt.message)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"github.com/padeir0/pir/backends/linuxamd64/mir"
+	mfk "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
 	mik "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
 )
 
@@ -58,3 +59,84 @@ func Copy(source, destination mir.Operand) *mir.Instr {
 		Dest: mir.OptOperand_(destination),
 	}
 }
+
+func Add(a, b, dest mir.Operand) mir.Instr  { return binary(mik.Add, a, b, dest) }
+func Sub(a, b, dest mir.Operand) mir.Instr  { return binary(mik.Sub, a, b, dest) }
+func Div(a, b, dest mir.Operand) mir.Instr  { return binary(mik.Div, a, b, dest) }
+func Mult(a, b, dest mir.Operand) mir.Instr { return binary(mik.Mult, a, b, dest) }
+func Rem(a, b, dest mir.Operand) mir.Instr  { return binary(mik.Rem, a, b, dest) }
+
+// comparisons are typed by their operands, dest must be bool
+func Eq(a, b, dest mir.Operand) mir.Instr     { return binary(mik.Eq, a, b, dest) }
+func Diff(a, b, dest mir.Operand) mir.Instr   { return binary(mik.Diff, a, b, dest) }
+func Less(a, b, dest mir.Operand) mir.Instr   { return binary(mik.Less, a, b, dest) }
+func More(a, b, dest mir.Operand) mir.Instr   { return binary(mik.More, a, b, dest) }
+func LessEq(a, b, dest mir.Operand) mir.Instr { return binary(mik.LessEq, a, b, dest) }
+func MoreEq(a, b, dest mir.Operand) mir.Instr { return binary(mik.MoreEq, a, b, dest) }
+
+func Or(a, b, dest mir.Operand) mir.Instr  { return binary(mik.Or, a, b, dest) }
+func And(a, b, dest mir.Operand) mir.Instr { return binary(mik.And, a, b, dest) }
+func Xor(a, b, dest mir.Operand) mir.Instr { return binary(mik.Xor, a, b, dest) }
+
+func ShiftLeft(a, b, dest mir.Operand) mir.Instr  { return binary(mik.ShiftLeft, a, b, dest) }
+func ShiftRight(a, b, dest mir.Operand) mir.Instr { return binary(mik.ShiftRight, a, b, dest) }
+
+func Not(a, dest mir.Operand) mir.Instr { return unary(mik.Not, a, dest) }
+func Neg(a, dest mir.Operand) mir.Instr { return unary(mik.Neg, a, dest) }
+
+// arguments and returns are passed through the callee interproc region
+func Call(proc mir.Operand) mir.Instr {
+	return mir.Instr{
+		T: mik.Call,
+		A: mir.OptOperand_(proc),
+	}
+}
+
+func binary(kind mik.InstrKind, a, b, dest mir.Operand) mir.Instr {
+	return mir.Instr{
+		T:    kind,
+		Type: a.Type,
+		A:    mir.OptOperand_(a),
+		B:    mir.OptOperand_(b),
+		Dest: mir.OptOperand_(dest),
+	}
+}
+
+func unary(kind mik.InstrKind, a, dest mir.Operand) mir.Instr {
+	return mir.Instr{
+		T:    kind,
+		Type: a.Type,
+		A:    mir.OptOperand_(a),
+		Dest: mir.OptOperand_(dest),
+	}
+}
+
+func Jmp(target mir.BlockID) mir.Flow {
+	return mir.Flow{
+		T:    mfk.Jmp,
+		True: target,
+	}
+}
+
+func Branch(cond mir.Operand, True, False mir.BlockID) mir.Flow {
+	return mir.Flow{
+		T:     mfk.If,
+		V:     []mir.Operand{cond},
+		True:  True,
+		False: False,
+	}
+}
+
+// returns are passed through the caller interproc region
+func Return() mir.Flow {
+	return mir.Flow{
+		T: mfk.Return,
+	}
+}
+
+func Exit(code mir.Operand) mir.Flow {
+	return mir.Flow{
+		T: mfk.Exit,
+		V: []mir.Operand{code},
+	}
+}
@@ -11,6 +11,7 @@ import (
 	mfk "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
 	mik "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
 
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
 
 	IRU "github.com/padeir0/pir/backends/linuxamd64/mir/util"
@@ -181,6 +182,9 @@ type state struct {
 
 	outputBlock *mir.BasicBlock
 	outputProc  *mir.Procedure
+
+	// span of the PIR instruction or flow being allocated
+	span *span.Span
 }
 
 func newState(program *pir.Program, numRegs int) *state {
@@ -324,6 +328,9 @@ func (s *state) UpdateMaxSpill(spill int) {
 
 // must preserve insertion order
 func (s *state) AddInstr(instr mir.Instr) {
+	if instr.Span == nil {
+		instr.Span = s.span
+	}
 	s.outputBlock.Code = append(s.outputBlock.Code, instr)
 }
 
@@ -481,6 +488,7 @@ func getUsedValues(instr pir.Instr) []value {
 
 func allocBlock(s *state) *mir.BasicBlock {
 	for i, instr := range s.hirBlock.Code {
+		s.span = instr.Span
 		switch instr.T {
 		case pik.Add, pik.Sub, pik.Mult, pik.Div, pik.Rem,
 			pik.Eq, pik.Diff, pik.Less,
@@ -498,6 +506,7 @@ func allocBlock(s *state) *mir.BasicBlock {
 			allocCall(s, instr, i)
 		}
 	}
+	s.span = s.hirBlock.Out.Span
 	if !s.hirBlock.IsTerminal() {
 		storeLiveLocals(s)
 	}
@@ -1069,6 +1078,7 @@ func hirToMirProc(proc *pir.Procedure) *mir.Procedure {
 		NumOfVars:               0,
 		NumOfSpills:             0,
 		NumOfMaxCalleeArguments: 0,
+		Span:                    proc.Span,
 	}
 }
 
@@ -1154,6 +1164,7 @@ func hirToMirBlock(b *pir.BasicBlock) *mir.BasicBlock {
 			V:     []mir.Operand{},
 			True:  mir.BlockID(b.Out.True),  // we can do this because we preserve ID numbers
 			False: mir.BlockID(b.Out.False), // between hir and mir
+			Span:  b.Out.Span,
 		},
 		Visited: false,
 	}
@@ -0,0 +1,590 @@
+package binenc
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	FT "github.com/padeir0/pir/flowkind"
+	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/span"
+	T "github.com/padeir0/pir/types"
+
+	"encoding/binary"
+	"io"
+	"strconv"
+)
+
+/*
+Layout (all integers are varints, strings and lists are length prefixed):
+
+	magic "PIR\x00", version
+	name, entry
+	types: count, {type}
+	symbols: count, {symbol}
+
+Types are stored once in a table and referenced by index everywhere else,
+a procedure type can only reference types that come before it. Lists that
+may be nil (operands, destinations, flow values) store length+1, with 0 for nil.
+Optional spans are a presence byte followed by file, line and column.
+*/
+
+// Version is bumped on every change to the layout, versions 1 to 3
+// were used by earlier layouts and are never read.
+const Version = 4
+
+var magic = []byte{'P', 'I', 'R', 0}
+
+const (
+	tagNil byte = iota
+	tagBasic
+	tagSpecial
+	tagProc
+	tagInvalid
+)
+
+const (
+	symProc byte = 1 << iota
+	symMem
+	symBuiltin
+	symExtern
+)
+
+// Encode writes P to w in the binary format
+func Encode(w io.Writer, P *pir.Program) *Error {
+	e := &encoder{
+		types:   []*T.Type{},
+		indexOf: map[string]uint64{},
+	}
+	collectTypes(e, P)
+
+	e.Bytes(magic)
+	e.Uint(Version)
+	e.String(P.Name)
+	e.Int(int64(P.Entry))
+	e.Uint(uint64(len(e.types)))
+	for _, t := range e.types {
+		encodeType(e, t)
+	}
+	e.Uint(uint64(len(P.Symbols)))
+	for _, sy := range P.Symbols {
+		encodeSymbol(e, sy)
+	}
+	_, err := w.Write(e.buff)
+	if err != nil {
+		return eu.NewEncodingError(err.Error())
+	}
+	return nil
+}
+
+// Decode reads a program written by Encode, equal types are
+// deduplicated and basic types are the ones in the types package.
+// Truncated or corrupt input results in an error.
+func Decode(r io.Reader) (*pir.Program, *Error) {
+	buff, err := io.ReadAll(r)
+	if err != nil {
+		return nil, eu.NewEncodingError(err.Error())
+	}
+	d := &decoder{
+		buff:     buff,
+		interner: T.NewInterner(),
+	}
+	for _, b := range magic {
+		if d.Byte() != b {
+			return nil, eu.NewEncodingError("not a PIR binary")
+		}
+	}
+	version := d.Uint()
+	if d.err == nil && version != Version {
+		return nil, eu.NewEncodingError("unsupported version: " + strconv.FormatUint(version, 10))
+	}
+	P := &pir.Program{}
+	P.Name = d.String()
+	P.Entry = pir.SymbolID(d.Int())
+	numTypes := d.Count()
+	for i := 0; i < numTypes && d.err == nil; i++ {
+		d.types = append(d.types, decodeType(d))
+	}
+	numSymbols := d.Count()
+	P.Symbols = make([]*pir.Symbol, 0, numSymbols)
+	for i := 0; i < numSymbols && d.err == nil; i++ {
+		P.Symbols = append(P.Symbols, decodeSymbol(d))
+	}
+	if d.err == nil && d.pos != len(d.buff) {
+		d.Fail("trailing bytes after program")
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return P, nil
+}
+
+type encoder struct {
+	buff    []byte
+	scratch [binary.MaxVarintLen64]byte
+
+	types   []*T.Type
+	indexOf map[string]uint64
+}
+
+func (e *encoder) Bytes(b []byte) {
+	e.buff = append(e.buff, b...)
+}
+
+func (e *encoder) Byte(b byte) {
+	e.buff = append(e.buff, b)
+}
+
+func (e *encoder) Uint(n uint64) {
+	size := binary.PutUvarint(e.scratch[:], n)
+	e.buff = append(e.buff, e.scratch[:size]...)
+}
+
+func (e *encoder) Int(n int64) {
+	size := binary.PutVarint(e.scratch[:], n)
+	e.buff = append(e.buff, e.scratch[:size]...)
+}
+
+func (e *encoder) String(s string) {
+	e.Uint(uint64(len(s)))
+	e.buff = append(e.buff, s...)
+}
+
+func (e *encoder) Span(sp *span.Span) {
+	if sp == nil {
+		e.Byte(0)
+		return
+	}
+	e.Byte(1)
+	e.String(sp.File)
+	e.Int(int64(sp.Line))
+	e.Int(int64(sp.Col))
+}
+
+func (e *encoder) Type(t *T.Type) {
+	e.Uint(e.indexOf[typeKey(t)])
+}
+
+func (e *encoder) Types(tps []*T.Type) {
+	e.Uint(uint64(len(tps)))
+	for _, t := range tps {
+		e.Type(t)
+	}
+}
+
+// the type String() is not enough to distinguish nil from invalid
+func typeKey(t *T.Type) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
+// children are added before their parents
+func (e *encoder) AddType(t *T.Type) {
+	key := typeKey(t)
+	if _, ok := e.indexOf[key]; ok {
+		return
+	}
+	if t != nil && t.Proc != nil && !T.IsBasic(t) && t.Special == T.InvalidSpecialType {
+		for _, arg := range t.Proc.Args {
+			e.AddType(arg)
+		}
+		for _, ret := range t.Proc.Rets {
+			e.AddType(ret)
+		}
+	}
+	e.indexOf[key] = uint64(len(e.types))
+	e.types = append(e.types, t)
+}
+
+func collectTypes(e *encoder, P *pir.Program) {
+	for _, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil {
+			continue
+		}
+		proc := sy.Proc
+		for _, tps := range [][]*T.Type{proc.Vars, proc.Args, proc.Rets} {
+			for _, t := range tps {
+				e.AddType(t)
+			}
+		}
+		for _, bb := range proc.AllBlocks {
+			if bb == nil {
+				continue
+			}
+			for _, instr := range bb.Code {
+				e.AddType(instr.Type)
+				for _, op := range instr.Operands {
+					e.AddType(op.Type)
+				}
+				for _, op := range instr.Destination {
+					e.AddType(op.Type)
+				}
+			}
+			for _, op := range bb.Out.V {
+				e.AddType(op.Type)
+			}
+		}
+	}
+}
+
+func encodeType(e *encoder, t *T.Type) {
+	switch {
+	case t == nil:
+		e.Byte(tagNil)
+	case T.IsBasic(t):
+		e.Byte(tagBasic)
+		e.Uint(uint64(t.Basic))
+	case t.Special != T.InvalidSpecialType:
+		e.Byte(tagSpecial)
+		e.Uint(uint64(t.Special))
+	case T.IsProc(t):
+		e.Byte(tagProc)
+		e.Types(t.Proc.Args)
+		e.Types(t.Proc.Rets)
+	default:
+		e.Byte(tagInvalid)
+	}
+}
+
+func encodeSymbol(e *encoder, sy *pir.Symbol) {
+	if sy == nil {
+		e.Byte(0)
+		return
+	}
+	var flags byte
+	if sy.Proc != nil {
+		flags |= symProc
+	}
+	if sy.Mem != nil {
+		flags |= symMem
+	}
+	if sy.Builtin {
+		flags |= symBuiltin
+	}
+	if sy.Extern {
+		flags |= symExtern
+	}
+	e.Byte(flags)
+	if sy.Proc != nil {
+		encodeProc(e, sy.Proc)
+	}
+	if sy.Mem != nil {
+		e.String(sy.Mem.Label)
+		e.String(sy.Mem.Data)
+		e.Uint(sy.Mem.Size)
+	}
+}
+
+func encodeProc(e *encoder, proc *pir.Procedure) {
+	e.String(proc.Label)
+	e.Types(proc.Vars)
+	e.Types(proc.Args)
+	e.Types(proc.Rets)
+	e.Int(int64(proc.Start))
+	e.Span(proc.Span)
+	e.Uint(uint64(len(proc.AllBlocks)))
+	for _, bb := range proc.AllBlocks {
+		if bb == nil {
+			bb = &pir.BasicBlock{}
+		}
+		e.String(bb.Label)
+		e.Uint(uint64(len(bb.Code)))
+		for _, instr := range bb.Code {
+			e.Uint(uint64(instr.T))
+			e.Type(instr.Type)
+			encodeOperands(e, instr.Operands)
+			encodeOperands(e, instr.Destination)
+			e.Span(instr.Span)
+		}
+		e.Uint(uint64(bb.Out.T))
+		encodeOperands(e, bb.Out.V)
+		e.Int(int64(bb.Out.True))
+		e.Int(int64(bb.Out.False))
+		e.Span(bb.Out.Span)
+	}
+}
+
+func encodeOperands(e *encoder, ops []pir.Operand) {
+	if ops == nil {
+		e.Uint(0)
+		return
+	}
+	e.Uint(uint64(len(ops)) + 1)
+	for _, op := range ops {
+		e.Uint(uint64(op.Class))
+		e.Type(op.Type)
+		e.Uint(op.Num)
+	}
+}
+
+type decoder struct {
+	buff []byte
+	pos  int
+	err  *Error
+
+	types    []*T.Type
+	interner *T.Interner
+}
+
+// only the first error is kept, after it every read returns zero
+func (d *decoder) Fail(message string) {
+	if d.err == nil {
+		d.err = eu.NewEncodingError("at byte " + strconv.Itoa(d.pos) + ": " + message)
+	}
+}
+
+func (d *decoder) Byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.buff) {
+		d.Fail("unexpected end of input")
+		return 0
+	}
+	b := d.buff[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) Uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(d.buff[d.pos:])
+	if size <= 0 {
+		d.Fail("malformed integer")
+		return 0
+	}
+	d.pos += size
+	return n
+}
+
+func (d *decoder) Int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Varint(d.buff[d.pos:])
+	if size <= 0 {
+		d.Fail("malformed integer")
+		return 0
+	}
+	d.pos += size
+	return n
+}
+
+// every item takes at least one byte, so counts
+// bigger than what's left are necessarily corrupt
+func (d *decoder) Count() int {
+	n := d.Uint()
+	if n > uint64(len(d.buff)-d.pos) {
+		d.Fail("count out of range: " + strconv.FormatUint(n, 10))
+		return 0
+	}
+	return int(n)
+}
+
+// counts stored as length+1, returns -1 for nil lists
+func (d *decoder) OptCount() int {
+	n := d.Uint()
+	if n == 0 {
+		return -1
+	}
+	if n-1 > uint64(len(d.buff)-d.pos) {
+		d.Fail("count out of range: " + strconv.FormatUint(n-1, 10))
+		return 0
+	}
+	return int(n - 1)
+}
+
+func (d *decoder) String() string {
+	size := d.Count()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.buff[d.pos : d.pos+size])
+	d.pos += size
+	return s
+}
+
+func (d *decoder) Span() *span.Span {
+	switch d.Byte() {
+	case 0:
+		return nil
+	case 1:
+		return &span.Span{
+			File: d.String(),
+			Line: int(d.Int()),
+			Col:  int(d.Int()),
+		}
+	}
+	d.Fail("invalid span")
+	return nil
+}
+
+func (d *decoder) Type() *T.Type {
+	i := d.Uint()
+	if d.err != nil {
+		return nil
+	}
+	if i >= uint64(len(d.types)) {
+		d.Fail("type index out of range: " + strconv.FormatUint(i, 10))
+		return nil
+	}
+	return d.types[i]
+}
+
+func (d *decoder) Types() []*T.Type {
+	n := d.Count()
+	output := make([]*T.Type, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		output = append(output, d.Type())
+	}
+	return output
+}
+
+var basicTypes = map[T.BasicType]*T.Type{
+	T.Bool: T.T_Bool,
+	T.I8:   T.T_I8,
+	T.I16:  T.T_I16,
+	T.I32:  T.T_I32,
+	T.I64:  T.T_I64,
+	T.U8:   T.T_U8,
+	T.U16:  T.T_U16,
+	T.U32:  T.T_U32,
+	T.U64:  T.T_U64,
+	T.Ptr:  T.T_Ptr,
+}
+
+// types can only reference types already decoded, so there are no cycles
+func decodeType(d *decoder) *T.Type {
+	switch d.Byte() {
+	case tagNil:
+		return nil
+	case tagBasic:
+		b := d.Uint()
+		t, ok := basicTypes[T.BasicType(b)]
+		if !ok {
+			d.Fail("invalid basic type: " + strconv.FormatUint(b, 10))
+			return nil
+		}
+		return t
+	case tagSpecial:
+		switch T.SpecialType(d.Uint()) {
+		case T.MultiRet:
+			return T.T_MultiRet
+		case T.Void:
+			return T.T_Void
+		}
+		d.Fail("invalid special type")
+		return nil
+	case tagProc:
+		args := d.Types()
+		rets := d.Types()
+		return d.interner.Proc(args, rets)
+	case tagInvalid:
+		return d.interner.Intern(&T.Type{})
+	}
+	d.Fail("invalid type tag")
+	return nil
+}
+
+func decodeSymbol(d *decoder) *pir.Symbol {
+	flags := d.Byte()
+	valid := symProc | symMem | symBuiltin | symExtern
+	if flags&^valid != 0 {
+		d.Fail("invalid symbol flags")
+		return nil
+	}
+	if flags == 0 {
+		return nil
+	}
+	if (flags&symProc != 0) == (flags&symMem != 0) {
+		d.Fail("symbol must be either a procedure or a memory declaration")
+		return nil
+	}
+	sy := &pir.Symbol{
+		Builtin: flags&symBuiltin != 0,
+		Extern:  flags&symExtern != 0,
+	}
+	if flags&symProc != 0 {
+		sy.Proc = decodeProc(d)
+	}
+	if flags&symMem != 0 {
+		sy.Mem = &pir.MemoryDecl{
+			Label: d.String(),
+			Data:  d.String(),
+			Size:  d.Uint(),
+		}
+	}
+	return sy
+}
+
+func decodeProc(d *decoder) *pir.Procedure {
+	proc := &pir.Procedure{
+		Label: d.String(),
+		Vars:  d.Types(),
+		Args:  d.Types(),
+		Rets:  d.Types(),
+		Start: pir.BlockID(d.Int()),
+		Span:  d.Span(),
+	}
+	numBlocks := d.Count()
+	proc.AllBlocks = make([]*pir.BasicBlock, 0, numBlocks)
+	for i := 0; i < numBlocks && d.err == nil; i++ {
+		bb := &pir.BasicBlock{Label: d.String()}
+		numInstrs := d.Count()
+		bb.Code = make([]pir.Instr, 0, numInstrs)
+		for j := 0; j < numInstrs && d.err == nil; j++ {
+			bb.Code = append(bb.Code, decodeInstr(d))
+		}
+		kind := FT.FlowKind(d.Uint())
+		if kind > FT.Exit {
+			d.Fail("invalid flow kind")
+		}
+		bb.Out = pir.Flow{
+			T:     kind,
+			V:     decodeOperands(d),
+			True:  pir.BlockID(d.Int()),
+			False: pir.BlockID(d.Int()),
+			Span:  d.Span(),
+		}
+		proc.AllBlocks = append(proc.AllBlocks, bb)
+	}
+	return proc
+}
+
+func decodeInstr(d *decoder) pir.Instr {
+	kind := IT.InstrKind(d.Uint())
+	if kind > IT.Call {
+		d.Fail("invalid instruction kind")
+	}
+	return pir.Instr{
+		T:           kind,
+		Type:        d.Type(),
+		Operands:    decodeOperands(d),
+		Destination: decodeOperands(d),
+		Span:        d.Span(),
+	}
+}
+
+func decodeOperands(d *decoder) []pir.Operand {
+	n := d.OptCount()
+	if n < 0 || d.err != nil {
+		return nil
+	}
+	output := make([]pir.Operand, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		class := hirc.Class(d.Uint())
+		if class > hirc.Global {
+			d.Fail("invalid operand class")
+		}
+		output = append(output, pir.Operand{
+			Class: class,
+			Type:  d.Type(),
+			Num:   d.Uint(),
+		})
+	}
+	return output
+}
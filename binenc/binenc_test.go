@@ -0,0 +1,184 @@
+package binenc
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/checker"
+	EC "github.com/padeir0/pir/errors/code"
+	"github.com/padeir0/pir/parse"
+	T "github.com/padeir0/pir/types"
+
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const sample = `Program: sample
+
+write: builtin
+msg: "hello\n"
+buff: 64
+square{
+i64, i32
+i64
+
+}:
+square_b0:
+	mult:i64 arg#0:i64, arg#0:i64 -> '0:i64
+	ret '0:i64
+
+
+main{
+
+
+i64, bool, i8
+}:
+main_b0:
+	call, global#3:proc[i64, i32][i64], 5, 2 -> local#0:i64
+	less:i64 local#0:i64, 30 -> '1:bool
+	copy:i8 -1 -> local#2:i8
+	if '1:bool? .L1 : .L2
+main_b1:
+	storeptr:i64 7, global#2:ptr
+	jmp .L2
+main_b2:
+	exit local#2:i8
+`
+
+const module = `Program: module
+
+ext: extern proc[i64][i64]
+data: extern mem
+f{
+i64
+i64
+
+}:
+b0:
+	call, global#0:proc[i64][i64], arg#0:i64 -> '0:i64
+	ret '0:i64
+`
+
+func program(t *testing.T, input string) *pir.Program {
+	P, err := parse.Program(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return P
+}
+
+func encode(t *testing.T, P *pir.Program) []byte {
+	var buff bytes.Buffer
+	err := Encode(&buff, P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buff.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"sample", sample},
+		{"module", module},
+	}
+	for _, tt := range tests {
+		P := program(t, tt.input)
+		Q, err := Decode(bytes.NewReader(encode(t, P)))
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if P.String() != Q.String() {
+			t.Errorf("%v: got:\n%v\nexpected:\n%v", tt.name, Q.String(), P.String())
+		}
+		if P.Entry != Q.Entry {
+			t.Errorf("%v: entry is %v, expected %v", tt.name, Q.Entry, P.Entry)
+		}
+		for i, sy := range P.Symbols {
+			if sy.Builtin != Q.Symbols[i].Builtin || sy.Extern != Q.Symbols[i].Extern {
+				t.Errorf("%v: flags of symbol %v differ", tt.name, i)
+			}
+		}
+		err = checker.CheckModule(Q)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		}
+	}
+}
+
+func TestBasicTypes(t *testing.T) {
+	Q, err := Decode(bytes.NewReader(encode(t, program(t, sample))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	square := Q.Symbols[3].Proc
+	if square.Args[0] != T.T_I64 || square.Args[1] != T.T_I32 || square.Rets[0] != T.T_I64 {
+		t.Errorf("basic types are not the ones in the types package")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	data := encode(t, program(t, sample))
+	for i := 0; i < len(data); i++ {
+		_, err := Decode(bytes.NewReader(data[:i]))
+		if err == nil {
+			t.Errorf("decoding %v of %v bytes didn't fail", i, len(data))
+			continue
+		}
+		if err.Code != EC.Encoding {
+			t.Errorf("error code is %v, expected %v", err.Code, EC.Encoding)
+		}
+	}
+}
+
+// corrupt input may decode to a different program, but neither
+// decoding nor checking it may panic
+func TestCorrupt(t *testing.T) {
+	data := encode(t, program(t, sample))
+	for i := range data {
+		for _, b := range []byte{0, 1, 3, 0x7F, 0x80, 0xFF} {
+			corrupt := append([]byte{}, data...)
+			corrupt[i] = b
+			Q, err := Decode(bytes.NewReader(corrupt))
+			if err == nil {
+				checker.CheckAll(Q)
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	header := func(version uint64) []byte {
+		e := &encoder{}
+		e.Bytes(magic)
+		e.Uint(version)
+		return e.buff
+	}
+	data := encode(t, program(t, module))
+	empty := program(t, module)
+	empty.Symbols = append(empty.Symbols, &pir.Symbol{Builtin: true})
+
+	tests := []struct {
+		name    string
+		input   []byte
+		message string
+	}{
+		{"empty", []byte{}, "not a PIR binary"},
+		{"bad magic", []byte("ELF\x00"), "not a PIR binary"},
+		{"old version", header(3), "unsupported version: 3"},
+		{"new version", header(Version + 1), "unsupported version"},
+		{"trailing bytes", append(append([]byte{}, data...), 0), "trailing bytes"},
+		{"empty symbol", encode(t, empty), "either a procedure or a memory declaration"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.input))
+		if err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Message, tt.message) {
+			t.Errorf("%v: error %q doesn't mention %q", tt.name, err.Message, tt.message)
+		}
+	}
+}
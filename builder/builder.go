@@ -0,0 +1,428 @@
+package builder
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/checker"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	EC "github.com/padeir0/pir/errors/code"
+	eu "github.com/padeir0/pir/errors/util"
+	"github.com/padeir0/pir/span"
+	T "github.com/padeir0/pir/types"
+	"github.com/padeir0/pir/util"
+
+	"strconv"
+)
+
+/*
+Builder constructs procedures of a program one instruction at a time.
+
+	b := builder.New(P)
+	b.NewProc("square", []*T.Type{T.T_I64}, []*T.Type{T.T_I64})
+	b.SetInsertPoint(b.NewBlock("entry"))
+	x := b.Mult(b.Arg(0), b.Arg(0))
+	b.Return(x)
+
+Emitters return fresh temps, numbered per procedure. Temps can only be
+used in the block they were defined in, use locals across blocks.
+Every instruction is validated with checker.CheckInstr as it is emitted,
+errors are collected instead of stopping the builder, see Err and Errors.
+*/
+type Builder struct {
+	Program *pir.Program
+
+	proc     *pir.Procedure
+	block    *pir.BasicBlock
+	nextTemp uint64
+	span     *span.Span
+
+	types  *T.Interner
+	errors []*Error
+}
+
+func New(P *pir.Program) *Builder {
+	return &Builder{
+		Program: P,
+		types:   T.NewInterner(),
+	}
+}
+
+// Err returns the first error found, if any.
+func (b *Builder) Err() *Error {
+	if len(b.errors) == 0 {
+		return nil
+	}
+	return b.errors[0]
+}
+
+// Errors returns every error found, in order.
+func (b *Builder) Errors() []*Error {
+	return b.errors
+}
+
+func (b *Builder) fail(err *Error) {
+	if b.proc != nil {
+		err.Proc = b.proc.Label
+		err = eu.WithSpan(b.span, err)
+	}
+	b.errors = append(b.errors, err)
+}
+
+// NewProc adds a procedure to the program and makes it the current one,
+// the insert point is cleared until SetInsertPoint is called.
+func (b *Builder) NewProc(label string, args, rets []*T.Type) pir.SymbolID {
+	if args == nil {
+		args = []*T.Type{}
+	}
+	if rets == nil {
+		rets = []*T.Type{}
+	}
+	proc := &pir.Procedure{
+		Label:     label,
+		Args:      args,
+		Rets:      rets,
+		Vars:      []*T.Type{},
+		AllBlocks: []*pir.BasicBlock{},
+		Span:      b.span,
+	}
+	id := b.Program.AddProc(proc)
+	b.proc = proc
+	b.block = nil
+	b.nextTemp = 0
+	return pir.SymbolID(id)
+}
+
+// Proc returns the current procedure.
+func (b *Builder) Proc() *pir.Procedure {
+	return b.proc
+}
+
+// NewBlock appends an empty block to the current procedure,
+// the first block is the start of the procedure.
+func (b *Builder) NewBlock(label string) pir.BlockID {
+	if b.proc == nil {
+		b.fail(noProc())
+		return -1
+	}
+	id := pir.BlockID(len(b.proc.AllBlocks))
+	b.proc.AllBlocks = append(b.proc.AllBlocks, &pir.BasicBlock{Label: label, Code: []pir.Instr{}})
+	return id
+}
+
+// SetInsertPoint makes new instructions be appended to the block id.
+func (b *Builder) SetInsertPoint(id pir.BlockID) {
+	if b.proc == nil {
+		b.fail(noProc())
+		return
+	}
+	if id < 0 || int(id) >= len(b.proc.AllBlocks) {
+		b.fail(invalidBlock(id))
+		b.block = nil
+		return
+	}
+	b.block = b.proc.AllBlocks[id]
+}
+
+// SetSpan attaches sp to everything emitted from now on,
+// nil stops attaching spans.
+func (b *Builder) SetSpan(sp *span.Span) {
+	b.span = sp
+}
+
+// NewLocal adds a local variable of type t to the current procedure.
+func (b *Builder) NewLocal(t *T.Type) pir.Operand {
+	if b.proc == nil {
+		b.fail(noProc())
+		return pir.Operand{}
+	}
+	num := uint64(len(b.proc.Vars))
+	b.proc.Vars = append(b.proc.Vars, t)
+	return pir.Operand{Class: hirc.Local, Type: t, Num: num}
+}
+
+// Local returns the i-th local variable of the current procedure.
+func (b *Builder) Local(i int) pir.Operand {
+	if b.proc == nil {
+		b.fail(noProc())
+		return pir.Operand{}
+	}
+	if i < 0 || i >= len(b.proc.Vars) {
+		b.fail(invalidOperand("local", i))
+		return pir.Operand{}
+	}
+	return pir.Operand{Class: hirc.Local, Type: b.proc.Vars[i], Num: uint64(i)}
+}
+
+// Arg returns the i-th argument of the current procedure.
+func (b *Builder) Arg(i int) pir.Operand {
+	if b.proc == nil {
+		b.fail(noProc())
+		return pir.Operand{}
+	}
+	if i < 0 || i >= len(b.proc.Args) {
+		b.fail(invalidOperand("argument", i))
+		return pir.Operand{}
+	}
+	return pir.Operand{Class: hirc.Arg, Type: b.proc.Args[i], Num: uint64(i)}
+}
+
+// Global returns an operand referencing a symbol of the program,
+// procedures have their procedure type, see T.Interner, and memory is a pointer.
+func (b *Builder) Global(id pir.SymbolID) pir.Operand {
+	if id < 0 || int(id) >= len(b.Program.Symbols) || b.Program.Symbols[id] == nil {
+		b.fail(invalidOperand("symbol", int(id)))
+		return pir.Operand{}
+	}
+	sy := b.Program.Symbols[id]
+	t := T.T_Ptr
+	if sy.Proc != nil {
+		t = b.types.Proc(sy.Proc.Args, sy.Proc.Rets)
+	}
+	return pir.Operand{Class: hirc.Global, Type: t, Num: uint64(id)}
+}
+
+// Lit returns a literal of type t.
+func (b *Builder) Lit(t *T.Type, value uint64) pir.Operand {
+	return util.UintLit(t, value)
+}
+
+// IntLit returns a literal of the signed type t.
+func (b *Builder) IntLit(t *T.Type, value int64) pir.Operand {
+	return util.IntLit(t, value)
+}
+
+func (b *Builder) newTemp(t *T.Type) pir.Operand {
+	num := b.nextTemp
+	b.nextTemp++
+	return pir.Operand{Class: hirc.Temp, Type: t, Num: num}
+}
+
+// appends instr to the current block if it's well formed
+func (b *Builder) emit(instr pir.Instr) {
+	if b.block == nil {
+		b.fail(noInsertPoint())
+		return
+	}
+	if b.block.HasFlow() {
+		b.fail(terminated(b.block))
+		return
+	}
+	if !b.typed(instr.Operands...) || !b.typed(instr.Destination...) {
+		return
+	}
+	err := checker.CheckInstr(b.Program, instr)
+	if err != nil {
+		b.fail(err)
+		return
+	}
+	instr.Span = b.span
+	b.block.AddInstr(instr)
+}
+
+type binaryFunc func(a, b, dest pir.Operand) pir.Instr
+type unaryFunc func(a, dest pir.Operand) pir.Instr
+
+func (b *Builder) binary(f binaryFunc, x, y pir.Operand, destType *T.Type) pir.Operand {
+	dest := b.newTemp(destType)
+	b.emit(f(x, y, dest))
+	return dest
+}
+
+func (b *Builder) unary(f unaryFunc, x pir.Operand, destType *T.Type) pir.Operand {
+	dest := b.newTemp(destType)
+	b.emit(f(x, dest))
+	return dest
+}
+
+func (b *Builder) Add(x, y pir.Operand) pir.Operand  { return b.binary(util.Add, x, y, x.Type) }
+func (b *Builder) Sub(x, y pir.Operand) pir.Operand  { return b.binary(util.Sub, x, y, x.Type) }
+func (b *Builder) Mult(x, y pir.Operand) pir.Operand { return b.binary(util.Mult, x, y, x.Type) }
+func (b *Builder) Div(x, y pir.Operand) pir.Operand  { return b.binary(util.Div, x, y, x.Type) }
+func (b *Builder) Rem(x, y pir.Operand) pir.Operand  { return b.binary(util.Rem, x, y, x.Type) }
+
+func (b *Builder) Eq(x, y pir.Operand) pir.Operand     { return b.binary(util.Eq, x, y, T.T_Bool) }
+func (b *Builder) Diff(x, y pir.Operand) pir.Operand   { return b.binary(util.Diff, x, y, T.T_Bool) }
+func (b *Builder) Less(x, y pir.Operand) pir.Operand   { return b.binary(util.Less, x, y, T.T_Bool) }
+func (b *Builder) More(x, y pir.Operand) pir.Operand   { return b.binary(util.More, x, y, T.T_Bool) }
+func (b *Builder) LessEq(x, y pir.Operand) pir.Operand { return b.binary(util.LessEq, x, y, T.T_Bool) }
+func (b *Builder) MoreEq(x, y pir.Operand) pir.Operand { return b.binary(util.MoreEq, x, y, T.T_Bool) }
+
+func (b *Builder) Or(x, y pir.Operand) pir.Operand  { return b.binary(util.Or, x, y, x.Type) }
+func (b *Builder) And(x, y pir.Operand) pir.Operand { return b.binary(util.And, x, y, x.Type) }
+func (b *Builder) Xor(x, y pir.Operand) pir.Operand { return b.binary(util.Xor, x, y, x.Type) }
+
+func (b *Builder) ShiftLeft(x, y pir.Operand) pir.Operand {
+	return b.binary(util.ShiftLeft, x, y, x.Type)
+}
+func (b *Builder) ShiftRight(x, y pir.Operand) pir.Operand {
+	return b.binary(util.ShiftRight, x, y, x.Type)
+}
+
+func (b *Builder) Not(x pir.Operand) pir.Operand { return b.unary(util.Not, x, x.Type) }
+func (b *Builder) Neg(x pir.Operand) pir.Operand { return b.unary(util.Neg, x, x.Type) }
+
+// Convert converts x to the type t.
+func (b *Builder) Convert(x pir.Operand, t *T.Type) pir.Operand {
+	return b.unary(util.Convert, x, t)
+}
+
+// LoadPtr loads a value of type t from ptr.
+func (b *Builder) LoadPtr(ptr pir.Operand, t *T.Type) pir.Operand {
+	return b.unary(util.LoadPtr, ptr, t)
+}
+
+// StorePtr stores x at ptr.
+func (b *Builder) StorePtr(x, ptr pir.Operand) {
+	b.emit(util.StorePtr(x, ptr))
+}
+
+// Copy copies x into a fresh temp.
+func (b *Builder) Copy(x pir.Operand) pir.Operand {
+	return b.unary(util.Copy, x, x.Type)
+}
+
+// CopyTo copies x into dest, usually a local or argument.
+func (b *Builder) CopyTo(dest, x pir.Operand) {
+	b.emit(util.Copy(x, dest))
+}
+
+// Call calls proc with args and returns one fresh temp for each return.
+func (b *Builder) Call(proc pir.Operand, args ...pir.Operand) []pir.Operand {
+	if !b.typed(proc) {
+		return nil
+	}
+	if !T.IsProc(proc.Type) {
+		b.fail(notProc(proc))
+		return nil
+	}
+	rets := make([]pir.Operand, len(proc.Type.Proc.Rets))
+	for i, t := range proc.Type.Proc.Rets {
+		rets[i] = b.newTemp(t)
+	}
+	b.emit(util.Call(proc, args, rets))
+	return rets
+}
+
+// the checker can't deal with missing types,
+// they come from helpers that already failed
+func (b *Builder) typed(ops ...pir.Operand) bool {
+	for _, op := range ops {
+		if op.Type == nil {
+			b.fail(untyped(op))
+			return false
+		}
+	}
+	return true
+}
+
+// flows must be the last thing emitted in a block
+func (b *Builder) flowBlock() *pir.BasicBlock {
+	if b.block == nil {
+		b.fail(noInsertPoint())
+		return nil
+	}
+	if b.block.HasFlow() {
+		b.fail(terminated(b.block))
+		return nil
+	}
+	return b.block
+}
+
+func (b *Builder) setFlow(bb *pir.BasicBlock, f pir.Flow) {
+	f.Span = b.span
+	bb.Out = f
+}
+
+func (b *Builder) Jmp(id pir.BlockID) {
+	bb := b.flowBlock()
+	if bb == nil {
+		return
+	}
+	b.setFlow(bb, util.Jmp(id))
+}
+
+func (b *Builder) Branch(cond pir.Operand, True, False pir.BlockID) {
+	bb := b.flowBlock()
+	if bb == nil {
+		return
+	}
+	if !b.typed(cond) {
+		return
+	}
+	if !T.IsBool(cond.Type) {
+		b.fail(notBool(cond))
+		return
+	}
+	b.setFlow(bb, util.Branch(cond, True, False))
+}
+
+func (b *Builder) Return(values ...pir.Operand) {
+	bb := b.flowBlock()
+	if bb == nil {
+		return
+	}
+	if !b.typed(values...) {
+		return
+	}
+	if len(values) != len(b.proc.Rets) {
+		b.fail(invalidNumOfRets(b.proc, values))
+		return
+	}
+	for i, v := range values {
+		if !v.Type.Equals(b.proc.Rets[i]) {
+			b.fail(badRet(b.proc.Rets[i], v))
+			return
+		}
+	}
+	b.setFlow(bb, util.Return(values...))
+}
+
+func (b *Builder) Exit(code pir.Operand) {
+	bb := b.flowBlock()
+	if bb == nil {
+		return
+	}
+	if !b.typed(code) {
+		return
+	}
+	if !code.Type.Equals(T.T_I8) {
+		b.fail(badExit(code))
+		return
+	}
+	b.setFlow(bb, util.Exit(code))
+}
+
+func noProc() *Error {
+	return eu.NewInternalSemanticError("no current procedure")
+}
+func noInsertPoint() *Error {
+	return eu.NewInternalSemanticError("no insert point")
+}
+func invalidBlock(id pir.BlockID) *Error {
+	return eu.NewInternalSemanticError("invalid block: " + strconv.Itoa(int(id)))
+}
+func invalidOperand(what string, i int) *Error {
+	return eu.NewInternalSemanticError("invalid " + what + ": " + strconv.Itoa(i))
+}
+func terminated(bb *pir.BasicBlock) *Error {
+	return eu.NewInternalSemanticError("block " + bb.Label + " already has a flow: " + bb.Out.String())
+}
+func untyped(op pir.Operand) *Error {
+	return eu.NewInternalSemanticError("operand without type: " + op.String())
+}
+func notProc(op pir.Operand) *Error {
+	return eu.NewCheckError(EC.ExpectedProc, nil, &op, "calling a non-procedure: "+op.String())
+}
+func notBool(op pir.Operand) *Error {
+	return eu.NewCheckError(EC.InvalidFlow, nil, &op, "branch condition is not bool: "+op.String())
+}
+func invalidNumOfRets(proc *pir.Procedure, values []pir.Operand) *Error {
+	return eu.NewCheckError(EC.ReturnNumOfValues, nil, nil, "expected "+strconv.Itoa(len(proc.Rets))+
+		" returns, instead found: "+strconv.Itoa(len(values)))
+}
+func badRet(t *T.Type, op pir.Operand) *Error {
+	return eu.NewCheckError(EC.ReturnBadType, nil, &op, "return "+op.String()+" doesn't match formal return "+t.String())
+}
+func badExit(op pir.Operand) *Error {
+	return eu.NewCheckError(EC.InvalidExit, nil, &op, "exit operand must be I8: "+op.String())
+}
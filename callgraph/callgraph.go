@@ -0,0 +1,242 @@
+package callgraph
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	IT "github.com/padeir0/pir/instrkind"
+	T "github.com/padeir0/pir/types"
+)
+
+/*
+Call graph of a whole program, built from the call instructions.
+
+A call through a Global is a direct edge to that symbol. A call through
+a temp, local or argument may reach any procedure with the same type,
+so it's an indirect edge to each of them. Builtins and externs are nodes
+without callees. Memory symbols are not part of the graph.
+*/
+
+type Edge struct {
+	From pir.SymbolID
+	To   pir.SymbolID
+	// true if every call of this edge is indirect
+	Indirect bool
+}
+
+type Graph struct {
+	Program *pir.Program
+	// in order of first appearance, without duplicates
+	Edges []Edge
+	// indexed by SymbolID, without duplicates
+	Callees [][]pir.SymbolID
+	Callers [][]pir.SymbolID
+
+	// strongly connected components, callees come before their callers
+	SCCs [][]pir.SymbolID
+	// index of the SCC of each symbol, -1 for memory
+	SCCOf []int
+	// procedures reachable from the entry point, the entry included
+	Reachable []bool
+
+	selfCalls map[pir.SymbolID]bool
+}
+
+// New builds the call graph of P.
+func New(P *pir.Program) *Graph {
+	n := len(P.Symbols)
+	g := &Graph{
+		Program:   P,
+		Edges:     []Edge{},
+		Callees:   make([][]pir.SymbolID, n),
+		Callers:   make([][]pir.SymbolID, n),
+		SCCOf:     make([]int, n),
+		Reachable: make([]bool, n),
+		selfCalls: map[pir.SymbolID]bool{},
+	}
+	edges := map[Edge]int{}
+	for i, sy := range P.Symbols {
+		if !hasBody(sy) {
+			continue
+		}
+		for _, bb := range sy.Proc.AllBlocks {
+			if bb == nil {
+				continue
+			}
+			for _, instr := range bb.Code {
+				if instr.T != IT.Call || len(instr.Operands) == 0 {
+					continue
+				}
+				for _, callee := range callees(P, instr.Operands[0]) {
+					g.addEdge(edges, pir.SymbolID(i), callee)
+				}
+			}
+		}
+	}
+	findSCCs(g)
+	findReachable(g)
+	return g
+}
+
+// IsRecursive reports whether the procedure may call itself,
+// directly or through other procedures.
+func (this *Graph) IsRecursive(id pir.SymbolID) bool {
+	scc := this.SCCOf[id]
+	return scc >= 0 && (len(this.SCCs[scc]) > 1 || this.selfCalls[id])
+}
+
+// IsLeaf reports whether the procedure has a body and calls nothing.
+func (this *Graph) IsLeaf(id pir.SymbolID) bool {
+	return hasBody(this.Program.Symbols[id]) && len(this.Callees[id]) == 0
+}
+
+// Leaves returns every leaf procedure, in symbol order.
+func (this *Graph) Leaves() []pir.SymbolID {
+	output := []pir.SymbolID{}
+	for i := range this.Program.Symbols {
+		if this.IsLeaf(pir.SymbolID(i)) {
+			output = append(output, pir.SymbolID(i))
+		}
+	}
+	return output
+}
+
+// Unreachable returns every procedure with a body
+// that can't be reached from the entry point, in symbol order.
+func (this *Graph) Unreachable() []pir.SymbolID {
+	output := []pir.SymbolID{}
+	for i, sy := range this.Program.Symbols {
+		if hasBody(sy) && !this.Reachable[i] {
+			output = append(output, pir.SymbolID(i))
+		}
+	}
+	return output
+}
+
+func (this *Graph) addEdge(edges map[Edge]int, from pir.SymbolID, c callee) {
+	key := Edge{From: from, To: c.id}
+	if i, ok := edges[key]; ok {
+		// a direct call makes the whole edge direct
+		if !c.indirect {
+			this.Edges[i].Indirect = false
+		}
+		return
+	}
+	edges[key] = len(this.Edges)
+	this.Edges = append(this.Edges, Edge{From: from, To: c.id, Indirect: c.indirect})
+	this.Callees[from] = append(this.Callees[from], c.id)
+	this.Callers[c.id] = append(this.Callers[c.id], from)
+	if from == c.id {
+		this.selfCalls[from] = true
+	}
+}
+
+type callee struct {
+	id       pir.SymbolID
+	indirect bool
+}
+
+func callees(P *pir.Program, op pir.Operand) []callee {
+	switch op.Class {
+	case hirc.Global:
+		if op.Num < uint64(len(P.Symbols)) && isProc(P.Symbols[op.Num]) {
+			return []callee{{id: pir.SymbolID(op.Num)}}
+		}
+	case hirc.Temp, hirc.Local, hirc.Arg:
+		if op.Type == nil || !T.IsProc(op.Type) {
+			return nil
+		}
+		output := []callee{}
+		for i, sy := range P.Symbols {
+			if isProc(sy) && sameSignature(op.Type.Proc, sy.Proc) {
+				output = append(output, callee{id: pir.SymbolID(i), indirect: true})
+			}
+		}
+		return output
+	}
+	return nil
+}
+
+func sameSignature(t *T.ProcType, proc *pir.Procedure) bool {
+	other := &T.ProcType{Args: proc.Args, Rets: proc.Rets}
+	return t.Equals(other)
+}
+
+func isProc(sy *pir.Symbol) bool {
+	return sy != nil && sy.Proc != nil
+}
+
+func hasBody(sy *pir.Symbol) bool {
+	return isProc(sy) && !sy.Builtin && !sy.Extern
+}
+
+// Tarjan's algorithm, components are found callees first
+func findSCCs(g *Graph) {
+	n := len(g.Program.Symbols)
+	index := make([]int, n)
+	lowlink := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+		g.SCCOf[i] = -1
+	}
+	stack := []pir.SymbolID{}
+	next := 0
+
+	var visit func(v pir.SymbolID)
+	visit = func(v pir.SymbolID) {
+		index[v] = next
+		lowlink[v] = next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range g.Callees[v] {
+			if index[w] == -1 {
+				visit(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+		if lowlink[v] != index[v] {
+			return
+		}
+		scc := []pir.SymbolID{}
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			g.SCCOf[w] = len(g.SCCs)
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		g.SCCs = append(g.SCCs, scc)
+	}
+	for i, sy := range g.Program.Symbols {
+		if isProc(sy) && index[i] == -1 {
+			visit(pir.SymbolID(i))
+		}
+	}
+}
+
+func findReachable(g *Graph) {
+	P := g.Program
+	if P.Entry < 0 || int(P.Entry) >= len(P.Symbols) || !isProc(P.Symbols[P.Entry]) {
+		return
+	}
+	stack := []pir.SymbolID{P.Entry}
+	g.Reachable[P.Entry] = true
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, callee := range g.Callees[id] {
+			if !g.Reachable[callee] {
+				g.Reachable[callee] = true
+				stack = append(stack, callee)
+			}
+		}
+	}
+}
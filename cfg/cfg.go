@@ -0,0 +1,167 @@
+package cfg
+
+import (
+	"github.com/padeir0/pir"
+	mir "github.com/padeir0/pir/backends/linuxamd64/mir"
+	mirFT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
+	FT "github.com/padeir0/pir/flowkind"
+)
+
+/*
+Control flow graph of a procedure, for PIR and MIR alike.
+
+Blocks are identified by their pir.BlockID, the index in AllBlocks,
+for MIR procedures it's the same as the mir.BlockID. Computing the
+graph doesn't touch the Visited flag of the blocks. Nil blocks have
+no successors and targets that are out of bounds are ignored, so that
+malformed procedures can still be inspected, see checker.Check.
+*/
+type CFG struct {
+	Start pir.BlockID
+	// successors and predecessors of each block, without duplicates,
+	// the true target of an If comes first. Unreachable blocks
+	// are still predecessors of their targets.
+	Succs [][]pir.BlockID
+	Preds [][]pir.BlockID
+
+	Reachable []bool
+	// only reachable blocks are ordered
+	PostOrder        []pir.BlockID
+	ReversePostOrder []pir.BlockID
+
+	// blocks ending in Return or Exit
+	Exits []pir.BlockID
+	// edges from a block with many successors
+	// to a block with many predecessors
+	CriticalEdges []Edge
+}
+
+type Edge struct {
+	From pir.BlockID
+	To   pir.BlockID
+}
+
+// New computes the CFG of a PIR procedure.
+func New(proc *pir.Procedure) *CFG {
+	succs := make([][]pir.BlockID, len(proc.AllBlocks))
+	exits := []pir.BlockID{}
+	for i, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		switch bb.Out.T {
+		case FT.Jmp:
+			succs[i] = targets(len(succs), bb.Out.True)
+		case FT.If:
+			succs[i] = targets(len(succs), bb.Out.True, bb.Out.False)
+		case FT.Return, FT.Exit:
+			exits = append(exits, pir.BlockID(i))
+		}
+	}
+	return build(proc.Start, succs, exits)
+}
+
+// NewMir computes the CFG of a MIR procedure.
+func NewMir(proc *mir.Procedure) *CFG {
+	succs := make([][]pir.BlockID, len(proc.AllBlocks))
+	exits := []pir.BlockID{}
+	for i, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		switch bb.Out.T {
+		case mirFT.Jmp:
+			succs[i] = targets(len(succs), pir.BlockID(bb.Out.True))
+		case mirFT.If:
+			succs[i] = targets(len(succs), pir.BlockID(bb.Out.True), pir.BlockID(bb.Out.False))
+		case mirFT.Return, mirFT.Exit:
+			exits = append(exits, pir.BlockID(i))
+		}
+	}
+	return build(pir.BlockID(proc.Start), succs, exits)
+}
+
+// IsCritical reports whether the edge from -> to is critical.
+func (this *CFG) IsCritical(from, to pir.BlockID) bool {
+	return len(this.Succs[from]) > 1 && len(this.Preds[to]) > 1
+}
+
+// drops duplicates and targets that are out of bounds
+func targets(size int, ids ...pir.BlockID) []pir.BlockID {
+	output := []pir.BlockID{}
+	for _, id := range ids {
+		if id < 0 || int(id) >= size || contains(output, id) {
+			continue
+		}
+		output = append(output, id)
+	}
+	return output
+}
+
+func contains(list []pir.BlockID, id pir.BlockID) bool {
+	for _, other := range list {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func build(start pir.BlockID, succs [][]pir.BlockID, exits []pir.BlockID) *CFG {
+	g := &CFG{
+		Start:     start,
+		Succs:     succs,
+		Preds:     make([][]pir.BlockID, len(succs)),
+		Reachable: make([]bool, len(succs)),
+		Exits:     exits,
+	}
+	for i, list := range succs {
+		for _, to := range list {
+			g.Preds[to] = append(g.Preds[to], pir.BlockID(i))
+		}
+	}
+	for i, list := range succs {
+		from := pir.BlockID(i)
+		for _, to := range list {
+			if g.IsCritical(from, to) {
+				g.CriticalEdges = append(g.CriticalEdges, Edge{From: from, To: to})
+			}
+		}
+	}
+	if start >= 0 && int(start) < len(succs) {
+		g.PostOrder = postOrder(g)
+	}
+	g.ReversePostOrder = make([]pir.BlockID, len(g.PostOrder))
+	for i, id := range g.PostOrder {
+		g.ReversePostOrder[len(g.PostOrder)-1-i] = id
+	}
+	return g
+}
+
+// iterative, so that long chains of blocks don't blow the stack,
+// also fills Reachable
+func postOrder(g *CFG) []pir.BlockID {
+	type frame struct {
+		id   pir.BlockID
+		next int // next successor to visit
+	}
+	output := []pir.BlockID{}
+	stack := []frame{{id: g.Start}}
+	g.Reachable[g.Start] = true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		succs := g.Succs[top.id]
+		if top.next < len(succs) {
+			succ := succs[top.next]
+			top.next++
+			if !g.Reachable[succ] {
+				g.Reachable[succ] = true
+				stack = append(stack, frame{id: succ})
+			}
+			continue
+		}
+		output = append(output, top.id)
+		stack = stack[:len(stack)-1]
+	}
+	return output
+}
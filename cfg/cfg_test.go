@@ -0,0 +1,174 @@
+package cfg
+
+import (
+	"github.com/padeir0/pir"
+	mir "github.com/padeir0/pir/backends/linuxamd64/mir"
+	mirFT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
+	"github.com/padeir0/pir/util"
+
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// blocks without successors return, blocks with one jump
+// and blocks with two branch, nil successors are nil blocks
+func proc(succs ...[]pir.BlockID) *pir.Procedure {
+	cond := util.BoolLit(true)
+	bbs := make([]*pir.BasicBlock, len(succs))
+	for i, s := range succs {
+		if s == nil {
+			continue
+		}
+		bbs[i] = &pir.BasicBlock{Label: "b" + strconv.Itoa(i)}
+		switch len(s) {
+		case 0:
+			bbs[i].Out = util.Return()
+		case 1:
+			bbs[i].Out = util.Jmp(s[0])
+		default:
+			bbs[i].Out = util.Branch(cond, s[0], s[1])
+		}
+	}
+	return &pir.Procedure{Label: "p", AllBlocks: bbs}
+}
+
+func ids(list ...pir.BlockID) []pir.BlockID { return append([]pir.BlockID{}, list...) }
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		proc     *pir.Procedure
+		succs    string
+		preds    string
+		rpo      string
+		reach    string
+		exits    string
+		critical string
+	}{
+		{
+			// 0 -> 1 -> 2 -> 1 | 3, 0 -> 3, 4 is unreachable
+			"loop",
+			proc(ids(1, 3), ids(2), ids(1, 3), ids(), ids(3)),
+			"[[1 3] [2] [1 3] [] [3]]",
+			"[[] [0 2] [1] [0 2 4] []]",
+			"[0 1 2 3]",
+			"[true true true true false]",
+			"[3]",
+			"[{0 1} {0 3} {2 1} {2 3}]",
+		},
+		{
+			// both 1 and 2 are entered from 0
+			"irreducible",
+			proc(ids(1, 2), ids(2), ids(1, 3), ids()),
+			"[[1 2] [2] [1 3] []]",
+			"[[] [0 2] [0 1] [2]]",
+			"[0 1 2 3]",
+			"[true true true true]",
+			"[3]",
+			"[{0 1} {0 2} {2 1}]",
+		},
+		{
+			"self loop on the start",
+			proc(ids(0, 1), ids()),
+			"[[0 1] []]",
+			"[[0] [0]]",
+			"[0 1]",
+			"[true true]",
+			"[1]",
+			"[]",
+		},
+		{
+			"infinite loop",
+			proc(ids(1), ids(2), ids(1)),
+			"[[1] [2] [1]]",
+			"[[] [0 2] [1]]",
+			"[0 1 2]",
+			"[true true true]",
+			"[]",
+			"[]",
+		},
+		{
+			"duplicated targets",
+			proc(ids(1, 1), ids()),
+			"[[1] []]",
+			"[[] [0]]",
+			"[0 1]",
+			"[true true]",
+			"[1]",
+			"[]",
+		},
+		{
+			"out of bounds and nil blocks",
+			proc(ids(7, 1), nil, ids(-1)),
+			"[[1] [] []]",
+			"[[] [0] []]",
+			"[0 1]",
+			"[true true false]",
+			"[]",
+			"[]",
+		},
+	}
+	for _, tt := range tests {
+		g := New(tt.proc)
+		check := func(field, got, expected string) {
+			if got != expected {
+				t.Errorf("%v: %v is %v, expected %v", tt.name, field, got, expected)
+			}
+		}
+		check("Succs", fmt.Sprint(g.Succs), tt.succs)
+		check("Preds", fmt.Sprint(g.Preds), tt.preds)
+		check("ReversePostOrder", fmt.Sprint(g.ReversePostOrder), tt.rpo)
+		check("Reachable", fmt.Sprint(g.Reachable), tt.reach)
+		check("Exits", fmt.Sprint(g.Exits), tt.exits)
+		check("CriticalEdges", fmt.Sprint(g.CriticalEdges), tt.critical)
+		for i, id := range g.PostOrder {
+			if g.ReversePostOrder[len(g.PostOrder)-1-i] != id {
+				t.Errorf("%v: ReversePostOrder is not the reverse of PostOrder", tt.name)
+			}
+		}
+	}
+}
+
+func TestStartOutOfBounds(t *testing.T) {
+	p := proc(ids())
+	p.Start = 1
+	g := New(p)
+	if len(g.PostOrder) != 0 || g.Reachable[0] {
+		t.Errorf("no block should be reachable: %v", g.Reachable)
+	}
+}
+
+// same as proc, but for MIR
+func mirProc(succs ...[]pir.BlockID) *mir.Procedure {
+	bbs := make([]*mir.BasicBlock, len(succs))
+	for i, s := range succs {
+		if s == nil {
+			continue
+		}
+		bbs[i] = &mir.BasicBlock{Label: "b" + strconv.Itoa(i)}
+		switch len(s) {
+		case 0:
+			bbs[i].Out = mir.Flow{T: mirFT.Return}
+		case 1:
+			bbs[i].Out = mir.Flow{T: mirFT.Jmp, True: mir.BlockID(s[0])}
+		default:
+			bbs[i].Out = mir.Flow{T: mirFT.If, True: mir.BlockID(s[0]), False: mir.BlockID(s[1])}
+		}
+	}
+	return &mir.Procedure{Label: "p", AllBlocks: bbs}
+}
+
+func TestNewMir(t *testing.T) {
+	tests := [][][]pir.BlockID{
+		{ids(1, 3), ids(2), ids(1, 3), ids(), ids(3)},
+		{ids(1, 2), ids(2), ids(1, 3), ids()},
+		{ids(7, 1), nil, ids(-1)},
+	}
+	for _, succs := range tests {
+		expected := fmt.Sprintf("%+v", *New(proc(succs...)))
+		if got := fmt.Sprintf("%+v", *NewMir(mirProc(succs...))); got != expected {
+			t.Errorf("got %v, expected %v", got, expected)
+		}
+	}
+}
@@ -5,70 +5,424 @@ import (
 
 	hir "github.com/padeir0/pir"
 	hirc "github.com/padeir0/pir/class"
+	EC "github.com/padeir0/pir/errors/code"
 	eu "github.com/padeir0/pir/errors/util"
 	FT "github.com/padeir0/pir/flowkind"
 	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
 
 	"strconv"
-	"strings"
 )
 
+// Check returns the first diagnostic found in P, or nil.
+// P must be a whole program, with a valid entry point,
+// see CheckModule for modules that are yet to be linked.
 func Check(P *hir.Program) *Error {
+	return first(check(P, false, true))
+}
+
+// CheckAll checks every procedure of P and returns all diagnostics found,
+// instead of stopping at the first one.
+func CheckAll(P *hir.Program) []*Error {
+	return check(P, true, true)
+}
+
+// CheckModule is the same as Check, but the entry point is ignored,
+// so that library modules, see link.Link, can be checked on their own.
+func CheckModule(P *hir.Program) *Error {
+	return first(check(P, false, false))
+}
+
+// CheckModuleAll is the same as CheckAll, but the entry point is ignored.
+func CheckModuleAll(P *hir.Program) []*Error {
+	return check(P, true, false)
+}
+
+func first(diags []*Error) *Error {
+	if len(diags) == 0 {
+		return nil
+	}
+	return diags[0]
+}
+
+func check(P *hir.Program, collect bool, entry bool) []*Error {
+	r := &reporter{collect: collect}
+	err := checkSymbols(P, r, entry)
+	if err != nil {
+		return r.diags
+	}
 	for _, sy := range P.Symbols {
-		if sy.Proc != nil && !sy.Builtin {
+		// malformed signatures are reported by checkSymbols
+		if validSymbol(sy) && sy.Proc != nil && !sy.Builtin && !sy.Extern && validSignature(sy.Proc) {
 			s := newState(P)
+			s.r = r
 			s.proc = sy.Proc
+			ok, err := checkBlocks(s)
+			if err != nil {
+				return r.diags
+			}
+			if !ok {
+				// walking the CFG is not safe
+				continue
+			}
 			sy.Proc.ResetBlocks()
-			err := checkCode(s, sy.Proc.FirstBlock())
+			err = checkCode(s, sy.Proc.Start)
+			if err != nil {
+				return r.diags
+			}
+			err = checkVisited(s)
+			if err != nil {
+				return r.diags
+			}
+		}
+	}
+	return r.diags
+}
+
+// CheckInstr checks a single instruction of P in isolation,
+// meant for tools that build code incrementally.
+func CheckInstr(P *hir.Program, instr hir.Instr) *Error {
+	return checkInstr(newState(P), instr)
+}
+
+// checks the symbol table and, if entry is set, the entry point,
+// the procedures rely on these
+func checkSymbols(P *hir.Program, r *reporter, entry bool) *Error {
+	labels := map[string]bool{}
+	for i, sy := range P.Symbols {
+		if !validSymbol(sy) {
+			err := r.Report(invalidSymbol(i))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		label := symbolLabel(sy)
+		if labels[label] {
+			err := r.Report(duplicatedSymbol(sy, label))
 			if err != nil {
 				return err
 			}
-			err = checkVisited(sy.Proc)
+		}
+		labels[label] = true
+		if sy.Proc != nil && !validSignature(sy.Proc) {
+			err := r.Report(malformedSignature(sy.Proc))
 			if err != nil {
 				return err
 			}
 		}
 	}
+	if !entry {
+		return nil
+	}
+	return r.Report(checkEntry(P))
+}
+
+func checkEntry(P *hir.Program) *Error {
+	if P.Entry < 0 || int(P.Entry) >= len(P.Symbols) {
+		return invalidEntry(P, "out of bounds")
+	}
+	sy := P.Symbols[P.Entry]
+	if !validSymbol(sy) || sy.Proc == nil {
+		return invalidEntry(P, "not a procedure")
+	}
+	if sy.Builtin {
+		return invalidEntry(P, "is a builtin")
+	}
+	if sy.Extern {
+		return invalidEntry(P, "is extern")
+	}
+	if !validSignature(sy.Proc) {
+		return invalidEntry(P, "has a malformed type")
+	}
+	t := procType(sy.Proc)
+	if !t.Equals(T.T_MainProc) {
+		return invalidEntry(P, "has type "+t.String()+", expected "+T.T_MainProc.String())
+	}
 	return nil
 }
 
+// checks globals against the symbol table and literals against their types
+func checkOperands(s *state, ops []hir.Operand) *Error {
+	for _, op := range ops {
+		var err *Error
+		switch op.Class {
+		case hirc.Global:
+			err = checkGlobal(s.m, op)
+		case hirc.Lit:
+			err = checkLit(op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the type of a global must agree with its symbol,
+// builtins don't have a signature, so any procedure type is accepted
+func checkGlobal(P *hir.Program, op hir.Operand) *Error {
+	if op.Num >= uint64(len(P.Symbols)) {
+		return globalOutOfBounds(op)
+	}
+	sy := P.Symbols[op.Num]
+	if !validSymbol(sy) {
+		return globalOutOfBounds(op)
+	}
+	if op.Type == nil {
+		return globalBadType(op, sy)
+	}
+	if sy.Proc != nil {
+		if !T.IsProc(op.Type) {
+			return globalBadType(op, sy)
+		}
+		if !sy.Builtin && (!validSignature(sy.Proc) || !op.Type.Equals(procType(sy.Proc))) {
+			return globalBadType(op, sy)
+		}
+		return nil
+	}
+	if !T.IsPtr(op.Type) {
+		return globalBadType(op, sy)
+	}
+	return nil
+}
+
+// signed literals are sign extended to 64 bits,
+// so -1:i8 is 0xFFFFFFFFFFFFFFFF and not 0xFF
+func checkLit(op hir.Operand) *Error {
+	if op.Type == nil || !T.IsBasic(op.Type) {
+		return nil
+	}
+	var ok bool
+	switch op.Type.Basic {
+	case T.Bool:
+		ok = op.Num <= 1
+	case T.I8, T.I16, T.I32:
+		bits := uint(op.Type.Size() * 8)
+		v := int64(op.Num)
+		ok = v >= -(1<<(bits-1)) && v <= (1<<(bits-1))-1
+	case T.U8, T.U16, T.U32:
+		bits := uint(op.Type.Size() * 8)
+		ok = op.Num < (1 << bits)
+	default: // i64, u64 and ptr take every value
+		ok = true
+	}
+	if !ok {
+		return litOutOfRange(op)
+	}
+	return nil
+}
+
+// nil, special and invalid types can't be compared, see T.Type.Equals,
+// so they are rejected before any comparison is made
+func validType(t *T.Type) bool {
+	if t == nil {
+		return false
+	}
+	if T.IsBasic(t) {
+		return true
+	}
+	if t.Special != T.InvalidSpecialType || t.Proc == nil {
+		return false
+	}
+	return validTypes(t.Proc.Args) && validTypes(t.Proc.Rets)
+}
+
+func validTypes(tps []*T.Type) bool {
+	for _, t := range tps {
+		if !validType(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func validOperandTypes(ops []hir.Operand) bool {
+	for _, op := range ops {
+		if !validType(op.Type) {
+			return false
+		}
+	}
+	return true
+}
+
+func validSignature(proc *hir.Procedure) bool {
+	return validTypes(proc.Args) && validTypes(proc.Rets) && validTypes(proc.Vars)
+}
+
+func validSymbol(sy *hir.Symbol) bool {
+	return sy != nil && (sy.Proc == nil) != (sy.Mem == nil)
+}
+
+func symbolLabel(sy *hir.Symbol) string {
+	if sy.Proc != nil {
+		return sy.Proc.Label
+	}
+	return sy.Mem.Label
+}
+
+func procType(proc *hir.Procedure) *T.Type {
+	return &T.Type{Proc: &T.ProcType{Args: proc.Args, Rets: proc.Rets}}
+}
+
+type reporter struct {
+	collect bool
+	diags   []*Error
+}
+
+// Report records err and returns it if checking should stop
+func (r *reporter) Report(err *Error) *Error {
+	if err == nil {
+		return nil
+	}
+	r.diags = append(r.diags, err)
+	if r.collect {
+		return nil
+	}
+	return err
+}
+
 type state struct {
 	m    *hir.Program
 	proc *hir.Procedure
 	bb   *hir.BasicBlock
+	id   hir.BlockID
+	r    *reporter
+
+	// temps defined so far in the current block
+	temps map[uint64]bool
 }
 
 func newState(P *hir.Program) *state {
 	return &state{
 		m: P,
+		r: &reporter{},
 	}
 }
 
-func checkVisited(proc *hir.Procedure) *Error {
-	notVisited := []string{}
-	for _, bb := range proc.AllBlocks {
-		if !bb.Visited {
-			notVisited = append(notVisited, bb.Label)
+// fills the location of err with the current block,
+// index is -1 for the flow
+func (s *state) locate(err *Error, index int, sp *span.Span) *Error {
+	err.Proc = s.proc.Label
+	err.Block = int(s.id)
+	err.Index = index
+	return eu.WithSpan(spanOf(s, sp), err)
+}
+
+// checkBlocks validates every block and flow of the procedure
+// before the CFG is walked, ok is false if any is invalid
+func checkBlocks(s *state) (ok bool, err *Error) {
+	ok = true
+	proc := s.proc
+	if !validBlockID(proc, proc.Start) {
+		ok = false
+		err = s.r.Report(eu.WithSpan(proc.Span, invalidStart(proc)))
+		if err != nil {
+			return ok, err
+		}
+	}
+	for i, bb := range proc.AllBlocks {
+		s.id = hir.BlockID(i)
+		if bb == nil {
+			ok = false
+			err = s.r.Report(s.locate(nilBlock(), -1, nil))
+			if err != nil {
+				return ok, err
+			}
+			continue
+		}
+		fErr := checkFlow(proc, bb.Out)
+		if fErr != nil {
+			ok = false
+			fErr.Instr = &bb.Out
+			err = s.r.Report(s.locate(fErr, -1, bb.Out.Span))
+			if err != nil {
+				return ok, err
+			}
+		}
+	}
+	return ok, nil
+}
+
+// return and exit are checked when reached
+func checkFlow(proc *hir.Procedure, f hir.Flow) *Error {
+	for i := range f.V {
+		if !validType(f.V[i].Type) {
+			return malformedOperandType(&f.V[i])
+		}
+	}
+	switch f.T {
+	case FT.Jmp:
+		if len(f.V) != 0 {
+			return invalidNumOfFlowOperands(f, 0)
+		}
+		if !validBlockID(proc, f.True) {
+			return blockOutOfBounds(f.True)
+		}
+	case FT.If:
+		if len(f.V) != 1 {
+			return invalidNumOfFlowOperands(f, 1)
 		}
+		cond := f.V[0]
+		if cond.Type == nil || !bool_oper.Check(cond) {
+			return invalidCondition(cond)
+		}
+		if !validBlockID(proc, f.True) {
+			return blockOutOfBounds(f.True)
+		}
+		if !validBlockID(proc, f.False) {
+			return blockOutOfBounds(f.False)
+		}
+	case FT.Return, FT.Exit:
+	default:
+		return invalidFlow(f)
 	}
-	if len(notVisited) > 0 {
-		return eu.NewInternalSemanticError(proc.Label +
-			": not all blocks are reachable (" +
-			strings.Join(notVisited, ", ") + ")")
+	return nil
+}
+
+func validBlockID(proc *hir.Procedure, id hir.BlockID) bool {
+	return id >= 0 && int(id) < len(proc.AllBlocks)
+}
+
+func checkVisited(s *state) *Error {
+	for i, bb := range s.proc.AllBlocks {
+		if !bb.Visited {
+			err := unreachableBlock(bb)
+			err.Proc = s.proc.Label
+			err.Block = i
+			err = s.r.Report(eu.WithSpan(s.proc.Span, err))
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func checkCode(s *state, bb *hir.BasicBlock) *Error {
+func checkCode(s *state, id hir.BlockID) *Error {
+	bb := s.proc.GetBlock(id)
 	if bb.Visited {
 		return nil
 	}
 	s.bb = bb
-	for _, instr := range bb.Code {
-		err := checkInstr(s, instr)
+	s.id = id
+	s.temps = map[uint64]bool{}
+	for i := range bb.Code {
+		instr := &bb.Code[i]
+		err := checkInstr(s, *instr)
+		tempErr := checkTemps(s, *instr)
+		if err == nil {
+			err = tempErr
+		}
 		if err != nil {
-			return err
+			if err.Instr == nil {
+				err.Instr = instr
+			}
+			err = s.r.Report(s.locate(err, i, instr.Span))
+			if err != nil {
+				return err
+			}
 		}
 	}
 	bb.Visited = true
@@ -77,38 +431,94 @@ func checkCode(s *state, bb *hir.BasicBlock) *Error {
 
 func checkJump(s *state) *Error {
 	bb := s.bb
+	err := checkOperands(s, bb.Out.V)
+	if err == nil {
+		err = checkTempUses(s, bb.Out.V)
+	}
+	if err != nil {
+		if err.Instr == nil {
+			err.Instr = &bb.Out
+		}
+		err = s.r.Report(s.locate(err, -1, bb.Out.Span))
+		if err != nil {
+			return err
+		}
+	}
 	switch bb.Out.T {
 	case FT.Jmp:
-		t := s.proc.GetBlock(bb.Out.True)
-		return checkCode(s, t)
+		return checkCode(s, bb.Out.True)
 	case FT.If:
-		t := s.proc.GetBlock(bb.Out.True)
-		err := checkCode(s, t)
+		err = checkCode(s, bb.Out.True)
 		if err != nil {
 			return err
 		}
-		f := s.proc.GetBlock(bb.Out.False)
-		return checkCode(s, f)
+		return checkCode(s, bb.Out.False)
 	case FT.Return:
-		return checkRet(s, bb.Out.V)
+		err = checkRet(s, bb.Out.V)
 	case FT.Exit:
-		return checkExit(s, bb.Out)
+		err = checkExit(s, bb.Out)
+	default:
+		err = invalidFlow(bb.Out)
+	}
+	if err == nil {
+		return nil
+	}
+	if err.Instr == nil {
+		err.Instr = &bb.Out
+	}
+	return s.r.Report(s.locate(err, -1, bb.Out.Span))
+}
+
+/*
+Temps are local to a block: the register allocator works one block at a time
+and doesn't keep temps alive across blocks. A temp must be defined before
+it's used in the same block, and can only be defined once per block.
+The same temp number may be reused in other blocks.
+*/
+func checkTemps(s *state, instr hir.Instr) *Error {
+	err := checkTempUses(s, instr.Operands)
+	for _, dest := range instr.Destination {
+		if dest.Class != hirc.Temp {
+			continue
+		}
+		if s.temps[dest.Num] && err == nil {
+			err = redefinedTemp(dest)
+		}
+		// even if invalid, to avoid cascading errors
+		s.temps[dest.Num] = true
+	}
+	return err
+}
+
+func checkTempUses(s *state, ops []hir.Operand) *Error {
+	for _, op := range ops {
+		if op.Class == hirc.Temp && !s.temps[op.Num] {
+			return undefinedTemp(op)
+		}
+	}
+	return nil
+}
+
+// falls back to the span of the procedure
+func spanOf(s *state, sp *span.Span) *span.Span {
+	if sp == nil {
+		return s.proc.Span
 	}
-	return invalidFlow(bb.Out)
+	return sp
 }
 
 func checkRet(s *state, rets []hir.Operand) *Error {
 	if len(s.proc.Rets) != len(rets) {
 		has := strconv.Itoa(len(rets))
 		wants := strconv.Itoa(len(s.proc.Rets))
-		return eu.NewInternalSemanticError("invalid number of returns: has " + has + " wanted " + wants)
+		return eu.NewCheckError(EC.ReturnNumOfValues, nil, nil, "invalid number of returns: has "+has+" wanted "+wants)
 	}
 	for i, wanted_ret := range s.proc.Rets {
 		curr_ret := rets[i]
 		if !wanted_ret.Equals(curr_ret.Type) {
 			has := curr_ret.Type.String()
 			wants := wanted_ret.String()
-			return eu.NewInternalSemanticError("invalid return for procedure: has " + has + " wanted " + wants)
+			return eu.NewCheckError(EC.ReturnBadType, nil, &curr_ret, "invalid return for procedure: has "+has+" wanted "+wants)
 		}
 	}
 	return nil
@@ -116,13 +526,13 @@ func checkRet(s *state, rets []hir.Operand) *Error {
 
 func checkExit(s *state, branch hir.Flow) *Error {
 	if branch.V == nil {
-		return eu.NewInternalSemanticError("invalid exit with zero operands")
+		return eu.NewCheckError(EC.InvalidExit, nil, nil, "invalid exit with zero operands")
 	}
 	if len(branch.V) != 1 {
-		return eu.NewInternalSemanticError("exit should have one operand")
+		return eu.NewCheckError(EC.InvalidExit, nil, nil, "exit should have one operand")
 	}
 	if !branch.V[0].Type.Equals(T.T_I8) {
-		return eu.NewInternalSemanticError("exit operand must be I8")
+		return eu.NewCheckError(EC.InvalidExit, nil, &branch.V[0], "exit operand must be I8")
 	}
 	return nil
 }
@@ -187,6 +597,24 @@ var ptr_res = Checker{
 }
 
 func checkInstr(s *state, instr hir.Instr) *Error {
+	if instr.T <= IT.InvalidInstr || instr.T > IT.Call {
+		return invalidInstrKind(instr)
+	}
+	// calls take their type from the procedure operand
+	if instr.T != IT.Call && !validType(instr.Type) {
+		return malformedTypeOrClass(instr)
+	}
+	if !validOperandTypes(instr.Operands) || !validOperandTypes(instr.Destination) {
+		return malformedTypeOrClass(instr)
+	}
+	err := checkOperands(s, instr.Operands)
+	if err != nil {
+		return err
+	}
+	err = checkOperands(s, instr.Destination)
+	if err != nil {
+		return err
+	}
 	switch instr.T {
 	case IT.Add, IT.Sub, IT.Div, IT.Mult, IT.Rem:
 		return checkArith(instr)
@@ -441,46 +869,113 @@ func checkForm(instr hir.Instr, numOperands int, hasDest bool) *Error {
 			return malformedInstr(instr)
 		}
 	}
-	if hasDest && instr.Destination == nil {
+	if hasDest && len(instr.Destination) == 0 {
 		return malformedInstr(instr)
 	}
 	return nil
 }
 
 func malformedInstr(instr hir.Instr) *Error {
-	return eu.NewInternalSemanticError("malformed instruction: " + instr.String())
+	return eu.NewCheckError(EC.MalformedInstr, &instr, nil, "malformed instruction: "+instr.String())
 }
 func malformedEqualTypes(instr hir.Instr) *Error {
-	return eu.NewInternalSemanticError("unequal types: " + instr.String())
+	return eu.NewCheckError(EC.UnequalTypes, &instr, nil, "unequal types: "+instr.String())
 }
 func malformedTypeOrClass(instr hir.Instr) *Error {
-	return eu.NewInternalSemanticError("malformed type or class: " + instr.String())
+	return eu.NewCheckError(EC.MalformedTypeOrClass, &instr, nil, "malformed type or class: "+instr.String())
+}
+func malformedOperandType(op *hir.Operand) *Error {
+	return eu.NewCheckError(EC.MalformedTypeOrClass, nil, op, "malformed type: "+op.String())
+}
+func malformedSignature(proc *hir.Procedure) *Error {
+	err := eu.NewCheckError(EC.MalformedTypeOrClass, nil, nil, "malformed type in the signature of "+proc.Label)
+	err.Proc = proc.Label
+	return err
 }
 func procArgNotFound(instr hir.Instr, p *hir.Procedure) *Error {
-	return eu.NewInternalSemanticError("argument " + p.Label + " not found in: " + instr.String())
+	return eu.NewCheckError(EC.CallBadArg, &instr, nil, "argument "+p.Label+" not found in: "+instr.String())
 }
 func procInvalidNumOfArgs(instr hir.Instr, p *T.ProcType) *Error {
 	n := strconv.Itoa(len(p.Args))
 	beepBop := strconv.Itoa(len(instr.Operands) - 1)
-	return eu.NewInternalSemanticError("expected " + n + " arguments, instead found: " + beepBop)
+	return eu.NewCheckError(EC.CallNumOfArgs, &instr, nil, "expected "+n+" arguments, instead found: "+beepBop)
 }
 func procInvalidNumOfRets(instr hir.Instr, p *T.ProcType) *Error {
 	n := strconv.Itoa(len(p.Rets))
 	beepBop := strconv.Itoa(len(instr.Destination))
-	return eu.NewInternalSemanticError("expected " + n + " returns, instead found: " + beepBop)
+	return eu.NewCheckError(EC.CallNumOfRets, &instr, nil, "expected "+n+" returns, instead found: "+beepBop)
 }
 func procBadArg(instr hir.Instr, d *T.Type, op hir.Operand) *Error {
-	return eu.NewInternalSemanticError("argument " + op.String() + " doesn't match formal parameter (" + d.String() + ") in: " + instr.String())
+	return eu.NewCheckError(EC.CallBadArg, &instr, &op, "argument "+op.String()+" doesn't match formal parameter ("+d.String()+") in: "+instr.String())
 }
 func procBadRet(instr hir.Instr, d *T.Type, op hir.Operand) *Error {
-	return eu.NewInternalSemanticError("return " + op.String() + " doesn't match formal return " + d.String() + " in: " + instr.String())
+	return eu.NewCheckError(EC.CallBadRet, &instr, &op, "return "+op.String()+" doesn't match formal return "+d.String()+" in: "+instr.String())
 }
 func invalidMirInstr(i hir.Instr) *Error {
-	return eu.NewInternalSemanticError("invalid MIR Instr: " + i.String())
+	return eu.NewCheckError(EC.MalformedInstr, &i, nil, "invalid MIR Instr: "+i.String())
+}
+func invalidInstrKind(instr hir.Instr) *Error {
+	return eu.NewCheckError(EC.InvalidInstrKind, &instr, nil, "invalid instruction kind: "+strconv.Itoa(int(instr.T)))
 }
 func invalidFlow(f hir.Flow) *Error {
-	return eu.NewInternalSemanticError("invalid flow: " + f.String())
+	return eu.NewCheckError(EC.InvalidFlow, &f, nil, "invalid flow: "+f.String())
 }
 func expectedProc(instr hir.Instr, o hir.Operand) *Error {
-	return eu.NewInternalSemanticError("expected procedure in: " + instr.String() + ", instead found: " + o.String())
+	return eu.NewCheckError(EC.ExpectedProc, &instr, &o, "expected procedure in: "+instr.String()+", instead found: "+o.String())
+}
+func unreachableBlock(bb *hir.BasicBlock) *Error {
+	return eu.NewCheckError(EC.UnreachableBlock, nil, nil, "unreachable block: "+bb.Label)
+}
+func invalidSymbol(i int) *Error {
+	return eu.NewCheckError(EC.InvalidSymbol, nil, nil, "symbol "+strconv.Itoa(i)+" must have either a procedure or memory")
+}
+func duplicatedSymbol(sy *hir.Symbol, label string) *Error {
+	err := eu.NewCheckError(EC.DuplicatedSymbol, nil, nil, "symbol "+label+" is defined more than once")
+	if sy.Proc != nil {
+		err.Proc = label
+	}
+	return err
+}
+func invalidEntry(P *hir.Program, message string) *Error {
+	return eu.NewCheckError(EC.InvalidEntry, nil, nil, "entry point ("+strconv.Itoa(int(P.Entry))+") "+message)
+}
+func globalOutOfBounds(op hir.Operand) *Error {
+	return eu.NewCheckError(EC.GlobalOutOfBounds, nil, &op, "global doesn't refer to a valid symbol: "+op.String())
+}
+func globalBadType(op hir.Operand, sy *hir.Symbol) *Error {
+	expected := T.T_Ptr.String()
+	if sy.Proc != nil {
+		expected = procType(sy.Proc).String()
+	}
+	return eu.NewCheckError(EC.GlobalBadType, nil, &op, "global "+op.String()+" doesn't match symbol "+symbolLabel(sy)+" ("+expected+")")
+}
+func invalidStart(proc *hir.Procedure) *Error {
+	err := eu.NewCheckError(EC.BlockOutOfBounds, nil, nil, "invalid start block: "+strconv.Itoa(int(proc.Start)))
+	err.Proc = proc.Label
+	return err
+}
+func invalidNumOfFlowOperands(f hir.Flow, n int) *Error {
+	return eu.NewCheckError(EC.InvalidFlow, &f, nil, f.T.String()+" expects "+strconv.Itoa(n)+" operands, instead found: "+strconv.Itoa(len(f.V)))
+}
+func nilBlock() *Error {
+	return eu.NewCheckError(EC.InvalidBlock, nil, nil, "nil block")
+}
+func blockOutOfBounds(id hir.BlockID) *Error {
+	return eu.NewCheckError(EC.BlockOutOfBounds, nil, nil, "block out of bounds: "+strconv.Itoa(int(id)))
+}
+func invalidCondition(op hir.Operand) *Error {
+	return eu.NewCheckError(EC.InvalidCondition, nil, &op, "condition must be bool: "+op.String())
+}
+func litOutOfRange(op hir.Operand) *Error {
+	value := strconv.FormatUint(op.Num, 10)
+	if T.IsInt(op.Type) {
+		value = strconv.FormatInt(int64(op.Num), 10)
+	}
+	return eu.NewCheckError(EC.LitOutOfRange, nil, &op, "literal "+value+" out of range for "+op.Type.String())
+}
+func undefinedTemp(op hir.Operand) *Error {
+	return eu.NewCheckError(EC.UndefinedTemp, nil, &op, "temp "+op.String()+" is used before being defined in this block")
+}
+func redefinedTemp(op hir.Operand) *Error {
+	return eu.NewCheckError(EC.RedefinedTemp, nil, &op, "temp "+op.String()+" is defined more than once in this block")
 }
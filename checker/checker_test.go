@@ -0,0 +1,438 @@
+package checker
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	EC "github.com/padeir0/pir/errors/code"
+	"github.com/padeir0/pir/parse"
+	T "github.com/padeir0/pir/types"
+	"github.com/padeir0/pir/util"
+
+	"testing"
+)
+
+const sample = `Program: sample
+
+write: builtin
+msg: "hello\n"
+buff: 64
+square{
+i64, i32
+i64
+
+}:
+square_b0:
+	mult:i64 arg#0:i64, arg#0:i64 -> '0:i64
+	ret '0:i64
+
+
+main{
+
+
+i64, bool
+}:
+main_b0:
+	call, global#3:proc[i64, i32][i64], 5, 2 -> local#0:i64
+	less:i64 local#0:i64, 30 -> '1:bool
+	if '1:bool? .L1 : .L2
+main_b1:
+	storeptr:i64 7, global#2:ptr
+	jmp .L2
+main_b2:
+	exit 0
+`
+
+type test struct {
+	name string
+	// main is the entry point of the sample, square is symbol 3
+	change func(P *pir.Program, main *pir.Procedure)
+	code   EC.Code // InvalidCode if valid
+}
+
+// each test is run with both Check and CheckAll, neither may panic
+func run(t *testing.T, tests []test) {
+	for _, tt := range tests {
+		for _, all := range []bool{false, true} {
+			P, err := parse.Program(sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(P, P.Symbols[P.Entry].Proc)
+			code := EC.InvalidCode
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%v: panic: %v", tt.name, r)
+					}
+				}()
+				if all {
+					diags := CheckAll(P)
+					if len(diags) > 0 {
+						code = diags[0].Code
+					}
+				} else if err := Check(P); err != nil {
+					code = err.Code
+				}
+			}()
+			if code != tt.code {
+				t.Errorf("%v: got %v, expected %v (CheckAll: %v)", tt.name, code, tt.code, all)
+			}
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	run(t, []test{
+		{"sample", func(P *pir.Program, main *pir.Procedure) {}, EC.InvalidCode},
+	})
+}
+
+func TestSymbols(t *testing.T) {
+	run(t, []test{
+		{
+			"entry out of bounds",
+			func(P *pir.Program, main *pir.Procedure) { P.Entry = 99 },
+			EC.InvalidEntry,
+		},
+		{
+			"no entry",
+			func(P *pir.Program, main *pir.Procedure) { P.Entry = pir.NoEntry },
+			EC.InvalidEntry,
+		},
+		{
+			"memory entry",
+			func(P *pir.Program, main *pir.Procedure) { P.Entry = 2 },
+			EC.InvalidEntry,
+		},
+		{
+			"builtin entry",
+			func(P *pir.Program, main *pir.Procedure) { P.Entry = 0 },
+			EC.InvalidEntry,
+		},
+		{
+			"extern entry",
+			func(P *pir.Program, main *pir.Procedure) {
+				P.Entry = pir.SymbolID(P.AddExternProc(&pir.Procedure{Label: "start"}))
+			},
+			EC.InvalidEntry,
+		},
+		{
+			"entry with arguments",
+			func(P *pir.Program, main *pir.Procedure) { main.Args = []*T.Type{T.T_I64} },
+			EC.InvalidEntry,
+		},
+		{
+			"empty symbol",
+			func(P *pir.Program, main *pir.Procedure) {
+				P.Symbols = append(P.Symbols, &pir.Symbol{Builtin: true})
+			},
+			EC.InvalidSymbol,
+		},
+		{
+			"nil symbol",
+			func(P *pir.Program, main *pir.Procedure) { P.Symbols = append(P.Symbols, nil) },
+			EC.InvalidSymbol,
+		},
+		{
+			"duplicated symbol",
+			func(P *pir.Program, main *pir.Procedure) {
+				P.AddMem(&pir.MemoryDecl{Label: "square", Size: 8})
+			},
+			EC.DuplicatedSymbol,
+		},
+		{
+			"global out of bounds",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[0].Code[0].Operands[0].Num = 99
+			},
+			EC.GlobalOutOfBounds,
+		},
+		{
+			"global to an empty symbol",
+			func(P *pir.Program, main *pir.Procedure) {
+				P.Symbols = append(P.Symbols, &pir.Symbol{})
+				main.AllBlocks[0].Code[0].Operands[0].Num = uint64(len(P.Symbols) - 1)
+			},
+			EC.InvalidSymbol,
+		},
+		{
+			"memory as a number",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[1].Code[0].Operands[1].Type = T.T_I64
+			},
+			EC.GlobalBadType,
+		},
+		{
+			"procedure of the wrong type",
+			func(P *pir.Program, main *pir.Procedure) {
+				op := &main.AllBlocks[0].Code[0].Operands[0]
+				op.Type = &T.Type{Proc: &T.ProcType{Args: []*T.Type{T.T_I64}, Rets: []*T.Type{T.T_I64}}}
+			},
+			EC.GlobalBadType,
+		},
+	})
+}
+
+func TestModule(t *testing.T) {
+	P, err := parse.Program(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	P.Entry = pir.NoEntry
+	if err := CheckModule(P); err != nil {
+		t.Errorf("CheckModule: %v", err)
+	}
+	if diags := CheckModuleAll(P); len(diags) != 0 {
+		t.Errorf("CheckModuleAll: %v", diags)
+	}
+	if err := Check(P); err == nil || err.Code != EC.InvalidEntry {
+		t.Errorf("Check: got %v, expected an invalid entry", err)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	run(t, []test{
+		{
+			"jump out of bounds",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[1].Out.True = 9 },
+			EC.BlockOutOfBounds,
+		},
+		{
+			"negative branch",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Out.False = -1 },
+			EC.BlockOutOfBounds,
+		},
+		{
+			"start out of bounds",
+			func(P *pir.Program, main *pir.Procedure) { main.Start = 3 },
+			EC.BlockOutOfBounds,
+		},
+		{
+			"no blocks",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks = nil },
+			EC.BlockOutOfBounds,
+		},
+		{
+			"nil block",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[1] = nil },
+			EC.InvalidBlock,
+		},
+		{
+			"invalid flow",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[1].Out = pir.Flow{} },
+			EC.InvalidFlow,
+		},
+		{
+			"branch without condition",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Out.V = nil },
+			EC.InvalidFlow,
+		},
+		{
+			"condition isn't bool",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[0].Out.V[0] = pir.Operand{Class: hirc.Local, Num: 0, Type: T.T_I64}
+			},
+			EC.InvalidCondition,
+		},
+		{
+			"unreachable block",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Out.True = 2 },
+			EC.UnreachableBlock,
+		},
+	})
+}
+
+func TestMalformedTypes(t *testing.T) {
+	square := func(P *pir.Program) *pir.Procedure { return P.Symbols[3].Proc }
+	run(t, []test{
+		{
+			"instruction without type",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Code[1].Type = nil },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"literal without type",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[1].Code[0].Operands[0].Type = nil },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"global without type",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[1].Code[0].Operands[1].Type = nil },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"void literal",
+			func(P *pir.Program, main *pir.Procedure) {
+				code := main.AllBlocks[1].Code
+				code[0].Type = T.T_Void
+				code[0].Operands[0].Type = T.T_Void
+			},
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"exit without type",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[2].Out.V[0].Type = nil },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"return without type",
+			func(P *pir.Program, main *pir.Procedure) { square(P).AllBlocks[0].Out.V[0].Type = nil },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"nil argument",
+			func(P *pir.Program, main *pir.Procedure) { square(P).Args[1] = nil },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"empty argument",
+			func(P *pir.Program, main *pir.Procedure) { square(P).Args[1] = &T.Type{} },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"special return",
+			func(P *pir.Program, main *pir.Procedure) { square(P).Rets[0] = T.T_MultiRet },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"nil entry argument",
+			func(P *pir.Program, main *pir.Procedure) { main.Args = []*T.Type{nil} },
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"procedure variable with a nil argument",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.Vars = append(main.Vars, &T.Type{Proc: &T.ProcType{Args: []*T.Type{nil}}})
+			},
+			EC.MalformedTypeOrClass,
+		},
+		{
+			"invalid instruction kind",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Code[1].T = 0 },
+			EC.InvalidInstrKind,
+		},
+		{
+			"unknown instruction kind",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Code[1].T = 999 },
+			EC.InvalidInstrKind,
+		},
+		{
+			"missing destination",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[0].Code[1].Destination = []pir.Operand{}
+			},
+			EC.MalformedInstr,
+		},
+	})
+}
+
+func TestLitRange(t *testing.T) {
+	tests := []struct {
+		op pir.Operand
+		ok bool
+	}{
+		{util.BoolLit(false), true},
+		{util.BoolLit(true), true},
+		{util.UintLit(T.T_Bool, 2), false},
+		{util.IntLit(T.T_I8, -128), true},
+		{util.IntLit(T.T_I8, 127), true},
+		{util.IntLit(T.T_I8, -129), false},
+		{util.IntLit(T.T_I8, 128), false},
+		{util.UintLit(T.T_I8, 255), false}, // not sign extended
+		{util.IntLit(T.T_I16, -32768), true},
+		{util.IntLit(T.T_I16, 32768), false},
+		{util.IntLit(T.T_I32, -2147483648), true},
+		{util.IntLit(T.T_I32, 2147483648), false},
+		{util.IntLit(T.T_I64, -9223372036854775808), true},
+		{util.UintLit(T.T_I64, 1<<64-1), true},
+		{util.UintLit(T.T_U8, 255), true},
+		{util.UintLit(T.T_U8, 256), false},
+		{util.IntLit(T.T_U8, -1), false},
+		{util.UintLit(T.T_U16, 65535), true},
+		{util.UintLit(T.T_U16, 65536), false},
+		{util.UintLit(T.T_U32, 1<<32-1), true},
+		{util.UintLit(T.T_U32, 1<<32), false},
+		{util.UintLit(T.T_U64, 1<<64-1), true},
+		{util.UintLit(T.T_Ptr, 1<<64-1), true},
+	}
+	for _, tt := range tests {
+		err := checkLit(tt.op)
+		if tt.ok && err != nil {
+			t.Errorf("%v: %v", tt.op, err)
+		}
+		if !tt.ok && (err == nil || err.Code != EC.LitOutOfRange) {
+			t.Errorf("%v: got %v, expected a literal out of range", tt.op, err)
+		}
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	run(t, []test{
+		{
+			"exit code out of range",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[2].Out.V[0] = util.UintLit(T.T_I8, 300) },
+			EC.LitOutOfRange,
+		},
+		{
+			"condition out of range",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[0].Out.V[0] = util.UintLit(T.T_Bool, 2) },
+			EC.LitOutOfRange,
+		},
+		{
+			"argument out of range",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[0].Code[0].Operands[2] = util.IntLit(T.T_I32, 1<<31)
+			},
+			EC.LitOutOfRange,
+		},
+		{
+			"negative exit code",
+			func(P *pir.Program, main *pir.Procedure) { main.AllBlocks[2].Out.V[0] = util.IntLit(T.T_I8, -1) },
+			EC.InvalidCode,
+		},
+	})
+}
+
+func TestTemps(t *testing.T) {
+	run(t, []test{
+		{
+			"used before defined",
+			func(P *pir.Program, main *pir.Procedure) {
+				// mult:i64 '0:i64, arg#0:i64 -> '0:i64
+				P.Symbols[3].Proc.AllBlocks[0].Code[0].Operands[0] = pir.Operand{Class: hirc.Temp, Num: 0, Type: T.T_I64}
+			},
+			EC.UndefinedTemp,
+		},
+		{
+			"never defined",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[2].Out.V[0] = pir.Operand{Class: hirc.Temp, Num: 9, Type: T.T_I8}
+			},
+			EC.UndefinedTemp,
+		},
+		{
+			"defined in another block",
+			func(P *pir.Program, main *pir.Procedure) {
+				main.AllBlocks[1].Out = pir.Flow{T: main.AllBlocks[0].Out.T, V: main.AllBlocks[0].Out.V, True: 2, False: 2}
+			},
+			EC.UndefinedTemp,
+		},
+		{
+			"defined twice",
+			func(P *pir.Program, main *pir.Procedure) {
+				bb := main.AllBlocks[0]
+				bb.Code = append(bb.Code, bb.Code[1])
+			},
+			EC.RedefinedTemp,
+		},
+		{
+			"reused in another block",
+			func(P *pir.Program, main *pir.Procedure) {
+				b0 := main.AllBlocks[0]
+				b1 := main.AllBlocks[1]
+				b1.Code = append([]pir.Instr{b0.Code[1]}, b1.Code...)
+			},
+			EC.InvalidCode,
+		},
+	})
+}
@@ -11,12 +11,35 @@ import (
 	mirchecker "github.com/padeir0/pir/backends/linuxamd64/mir/checker"
 	mirc "github.com/padeir0/pir/backends/linuxamd64/mir/class"
 	mk "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
+	mirparse "github.com/padeir0/pir/backends/linuxamd64/mir/parse"
+	"github.com/padeir0/pir/binenc"
+	"github.com/padeir0/pir/builder"
+	"github.com/padeir0/pir/callgraph"
+	"github.com/padeir0/pir/cfg"
 	pirchecker "github.com/padeir0/pir/checker"
 	pirc "github.com/padeir0/pir/class"
+	"github.com/padeir0/pir/constfold"
+	"github.com/padeir0/pir/dce"
+	"github.com/padeir0/pir/defuse"
+	"github.com/padeir0/pir/dom"
+	"github.com/padeir0/pir/dot"
+	EC "github.com/padeir0/pir/errors/code"
+	sv "github.com/padeir0/pir/errors/severity"
 	ik "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/jsonenc"
+	"github.com/padeir0/pir/link"
+	"github.com/padeir0/pir/lint"
+	"github.com/padeir0/pir/liveness"
+	"github.com/padeir0/pir/loops"
+	"github.com/padeir0/pir/parse"
+	"github.com/padeir0/pir/pass"
+	"github.com/padeir0/pir/printer"
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
+	"github.com/padeir0/pir/uninit"
 
 	"fmt"
+	"os"
 )
 
 func main() {
@@ -24,9 +47,39 @@ func main() {
 	fmt.Println(ik.Add)
 	fmt.Println(mk.Add)
 	mirchecker.Check(&mir.Program{})
+	mirparse.Program("")
 	pirchecker.Check(&pir.Program{})
-	fasm.Generate(&mir.Program{})
+	parse.Program("")
+	printer.String(&pir.Program{})
+	binenc.Encode(os.Stdout, &pir.Program{})
+	binenc.Decode(os.Stdin)
+	builder.New(&pir.Program{}).Err()
+	jsonenc.Encode(os.Stdout, &pir.Program{})
+	jsonenc.Decode(os.Stdin)
+	link.Link(&pir.Program{})
+	dot.Program(os.Stdout, &pir.Program{}, dot.Options{})
+	dot.MirProgram(os.Stdout, &mir.Program{}, dot.Options{CallGraph: true})
+	fasm.Generate(&mir.Program{}, "")
 	linuxamd64.GenerateFasm(&pir.Program{})
 	fmt.Println(mirc.Lit)
 	fmt.Println(pirc.Lit)
+	fmt.Println(&span.Span{})
+	fmt.Println(EC.Internal, sv.Warning)
+	pirchecker.CheckAll(&pir.Program{})
+	pirchecker.CheckModule(&pir.Program{})
+	mirchecker.CheckAll(&mir.Program{})
+	uninit.Check(&pir.Program{}, uninit.Warn)
+	lint.Lint(&pir.Program{}, lint.All)
+	cfg.New(&pir.Procedure{})
+	cfg.NewMir(&mir.Procedure{})
+	dom.New(&pir.Procedure{}).Dominates(0, 0)
+	dom.NewPost(&pir.Procedure{})
+	liveness.New(&pir.Procedure{})
+	loops.Check(&pir.Program{})
+	callgraph.New(&pir.Program{}).Leaves()
+	defuse.New(&pir.Procedure{}).Invalidate()
+	pass.New(&pir.Program{}).RunPipeline("O1")
+	dce.Blocks(&pir.Procedure{})
+	constfold.Program(&pir.Program{})
+	linuxamd64.GenerateFasmWith(&pir.Program{}, "O2")
 }
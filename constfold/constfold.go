@@ -0,0 +1,411 @@
+package constfold
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+	hirc "github.com/padeir0/pir/class"
+	FT "github.com/padeir0/pir/flowkind"
+	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/liveness"
+	T "github.com/padeir0/pir/types"
+)
+
+/*
+Constant folding and propagation.
+
+Instructions whose operands are all literals become copies of their
+result, and the values they define are replaced by the literal in the
+instructions that read them, including in other blocks, as long as every
+path agrees on the value. If flows on literal conditions become jumps.
+
+Arithmetic wraps around at the width of the type, signed values are
+sign extended and unsigned ones are zero extended. Shift counts are
+masked like in amd64: to 6 bits for 64 bit types, 5 bits for the rest.
+Divisions that would fault, by zero or of the minimum signed value
+by -1, are never folded, and neither are conversions to bool. These are
+the semantics documented in instrkind.
+
+Blocks that become unreachable are left in place, see dce.Blocks,
+and so are the copies, see dce.Instrs. The program must be valid,
+see checker.Check.
+*/
+
+// Program folds every procedure of P, it reports whether anything changed.
+func Program(P *pir.Program) bool {
+	changed := false
+	for _, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil || sy.Builtin || sy.Extern {
+			continue
+		}
+		if Procedure(sy.Proc) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Procedure is the same as Program, but for a single procedure.
+func Procedure(proc *pir.Procedure) bool {
+	in := propagate(proc)
+	changed := false
+	for id, bb := range proc.AllBlocks {
+		if bb == nil || in[id] == nil {
+			continue
+		}
+		if rewrite(bb, in[id]) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Fold evaluates an instruction whose operands are all literals,
+// ok is false if it can't be folded.
+func Fold(instr pir.Instr) (result pir.Operand, ok bool) {
+	if len(instr.Destination) != 1 || instr.Type == nil || !T.IsBasic(instr.Type) {
+		return pir.Operand{}, false
+	}
+	ops := make([]uint64, len(instr.Operands))
+	for i, op := range instr.Operands {
+		if op.Class != hirc.Lit || op.Type == nil || !T.IsBasic(op.Type) {
+			return pir.Operand{}, false
+		}
+		ops[i] = normalize(op.Type, op.Num)
+	}
+	dest := instr.Destination[0]
+	v, ok := eval(instr.T, instr.Type, ops)
+	if !ok {
+		return pir.Operand{}, false
+	}
+	return pir.Operand{Class: hirc.Lit, Type: dest.Type, Num: normalize(dest.Type, v)}, true
+}
+
+// values known to be literals, a nil state is a block not yet reached
+type state map[liveness.Value]pir.Operand
+
+func (s state) Copy() state {
+	out := make(state, len(s))
+	for v, lit := range s {
+		out[v] = lit
+	}
+	return out
+}
+
+func (s state) Equals(other state) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for v, lit := range s {
+		o, ok := other[v]
+		if !ok || !sameLit(lit, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// computes the literals known at the start of each reachable block.
+// Predecessors not yet reached don't constrain the meet,
+// and at entry nothing is known.
+func propagate(proc *pir.Procedure) []state {
+	g := cfg.New(proc)
+	n := len(proc.AllBlocks)
+	in := make([]state, n)
+	out := make([]state, n)
+	changed := true
+	for changed {
+		changed = false
+		for _, id := range g.ReversePostOrder {
+			bb := proc.AllBlocks[id]
+			if bb == nil {
+				continue
+			}
+			var s state
+			if id == g.Start {
+				s = state{}
+			} else {
+				s = meet(out, g.Preds[id])
+			}
+			in[id] = s.Copy()
+			transfer(bb, s)
+			if out[id] == nil || !s.Equals(out[id]) {
+				out[id] = s
+				changed = true
+			}
+		}
+	}
+	return in
+}
+
+func meet(out []state, preds []pir.BlockID) state {
+	var s state
+	for _, pred := range preds {
+		if out[pred] == nil {
+			continue
+		}
+		if s == nil {
+			s = out[pred].Copy()
+			continue
+		}
+		for v, lit := range s {
+			other, ok := out[pred][v]
+			if !ok || !sameLit(lit, other) {
+				delete(s, v)
+			}
+		}
+	}
+	if s == nil {
+		return state{}
+	}
+	return s
+}
+
+func transfer(bb *pir.BasicBlock, s state) {
+	for _, instr := range bb.Code {
+		instr.Operands = substitute(instr, s)
+		step(instr, s)
+	}
+	// temps don't outlive their block
+	for v := range s {
+		if v.Class == hirc.Temp {
+			delete(s, v)
+		}
+	}
+}
+
+// rewrites the block from the literals known at its start
+func rewrite(bb *pir.BasicBlock, s state) bool {
+	changed := false
+	for i := range bb.Code {
+		instr := &bb.Code[i]
+		ops := substitute(*instr, s)
+		if !sameOperands(ops, instr.Operands) {
+			instr.Operands = ops
+			changed = true
+		}
+		if instr.T != IT.Copy {
+			if lit, ok := Fold(*instr); ok {
+				instr.T = IT.Copy
+				instr.Type = lit.Type
+				instr.Operands = []pir.Operand{lit}
+				changed = true
+			}
+		}
+		step(*instr, s)
+	}
+	for i, op := range bb.Out.V {
+		if lit, ok := known(op, s); ok {
+			bb.Out.V[i] = lit
+			changed = true
+		}
+	}
+	if bb.Out.T == FT.If && len(bb.Out.V) == 1 && bb.Out.V[0].Class == hirc.Lit {
+		target := bb.Out.False
+		if bb.Out.V[0].Num != 0 {
+			target = bb.Out.True
+		}
+		bb.Out.T = FT.Jmp
+		bb.Out.V = nil
+		bb.Out.True = target
+		bb.Out.False = 0
+		changed = true
+	}
+	return changed
+}
+
+// updates s with the destinations of instr,
+// its operands must already be substituted
+func step(instr pir.Instr, s state) {
+	lit, ok := Fold(instr)
+	for _, dest := range instr.Destination {
+		v, isValue := liveness.ValueOf(dest)
+		if !isValue {
+			continue
+		}
+		if ok {
+			s[v] = lit
+		} else {
+			delete(s, v)
+		}
+	}
+}
+
+// returns the operands of instr with the known values replaced,
+// addresses are left alone, the slice is a copy
+func substitute(instr pir.Instr, s state) []pir.Operand {
+	ops := make([]pir.Operand, len(instr.Operands))
+	for i, op := range instr.Operands {
+		ops[i] = op
+		if isAddress(instr, i) {
+			continue
+		}
+		if lit, ok := known(op, s); ok {
+			ops[i] = lit
+		}
+	}
+	return ops
+}
+
+func isAddress(instr pir.Instr, i int) bool {
+	switch instr.T {
+	case IT.LoadPtr:
+		return i == 0
+	case IT.StorePtr:
+		return i == 1
+	case IT.Call:
+		return i == 0
+	}
+	return false
+}
+
+func known(op pir.Operand, s state) (pir.Operand, bool) {
+	v, ok := liveness.ValueOf(op)
+	if !ok {
+		return pir.Operand{}, false
+	}
+	lit, ok := s[v]
+	if !ok {
+		return pir.Operand{}, false
+	}
+	return pir.Operand{Class: hirc.Lit, Type: op.Type, Num: lit.Num}, true
+}
+
+func eval(kind IT.InstrKind, t *T.Type, ops []uint64) (uint64, bool) {
+	signed := T.IsInt(t)
+	switch kind {
+	case IT.Add, IT.Sub, IT.Mult, IT.Div, IT.Rem, IT.Or, IT.And, IT.Xor,
+		IT.ShiftLeft, IT.ShiftRight,
+		IT.Eq, IT.Diff, IT.Less, IT.More, IT.LessEq, IT.MoreEq:
+		if len(ops) != 2 {
+			return 0, false
+		}
+	case IT.Neg, IT.Not, IT.Convert, IT.Copy:
+		if len(ops) != 1 {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	switch kind {
+	case IT.Add:
+		return ops[0] + ops[1], true
+	case IT.Sub:
+		return ops[0] - ops[1], true
+	case IT.Mult:
+		return ops[0] * ops[1], true
+	case IT.Div, IT.Rem:
+		return divide(kind, t, signed, ops[0], ops[1])
+	case IT.Or:
+		return ops[0] | ops[1], true
+	case IT.And:
+		return ops[0] & ops[1], true
+	case IT.Xor:
+		return ops[0] ^ ops[1], true
+	case IT.ShiftLeft:
+		return ops[0] << shiftCount(t, ops[1]), true
+	case IT.ShiftRight:
+		if signed {
+			return uint64(int64(ops[0]) >> shiftCount(t, ops[1])), true
+		}
+		return ops[0] >> shiftCount(t, ops[1]), true
+	case IT.Eq:
+		return boolean(ops[0] == ops[1]), true
+	case IT.Diff:
+		return boolean(ops[0] != ops[1]), true
+	case IT.Less:
+		return boolean(less(signed, ops[0], ops[1])), true
+	case IT.More:
+		return boolean(less(signed, ops[1], ops[0])), true
+	case IT.LessEq:
+		return boolean(!less(signed, ops[1], ops[0])), true
+	case IT.MoreEq:
+		return boolean(!less(signed, ops[0], ops[1])), true
+	case IT.Neg:
+		return -ops[0], true
+	case IT.Not:
+		if T.IsBool(t) {
+			return ops[0] ^ 1, true
+		}
+		return ^ops[0], true
+	case IT.Convert:
+		// the operand is already extended according to its own type
+		if T.IsBool(t) {
+			return 0, false
+		}
+		return ops[0], true
+	case IT.Copy:
+		return ops[0], true
+	}
+	return 0, false
+}
+
+func divide(kind IT.InstrKind, t *T.Type, signed bool, a, b uint64) (uint64, bool) {
+	if b == 0 {
+		return 0, false
+	}
+	if !signed {
+		if kind == IT.Div {
+			return a / b, true
+		}
+		return a % b, true
+	}
+	bits := uint(t.Size() * 8)
+	min := int64(-1) << (bits - 1)
+	if int64(a) == min && int64(b) == -1 {
+		return 0, false
+	}
+	if kind == IT.Div {
+		return uint64(int64(a) / int64(b)), true
+	}
+	return uint64(int64(a) % int64(b)), true
+}
+
+func shiftCount(t *T.Type, count uint64) uint64 {
+	if t.Size() == 8 {
+		return count & 63
+	}
+	return count & 31
+}
+
+// values are normalized, so signed ones compare as int64
+func less(signed bool, a, b uint64) bool {
+	if signed {
+		return int64(a) < int64(b)
+	}
+	return a < b
+}
+
+func boolean(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// truncates v to the width of t and extends it back to 64 bits
+func normalize(t *T.Type, v uint64) uint64 {
+	if T.IsBool(t) {
+		return v & 1
+	}
+	bits := uint(t.Size() * 8)
+	if bits == 64 {
+		return v
+	}
+	if T.IsInt(t) {
+		return uint64(int64(v<<(64-bits)) >> (64 - bits))
+	}
+	return v & (1<<bits - 1)
+}
+
+func sameLit(a, b pir.Operand) bool {
+	return a.Num == b.Num && a.Type.Equals(b.Type)
+}
+
+func sameOperands(a, b []pir.Operand) bool {
+	for i := range a {
+		if a[i].Class != b[i].Class || a[i].Num != b[i].Num {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package constfold
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/parse"
+	"github.com/padeir0/pir/printer"
+	T "github.com/padeir0/pir/types"
+	"github.com/padeir0/pir/util"
+
+	"strings"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	i8, u8, u16, i32, u32, i64, u64, b := T.T_I8, T.T_U8, T.T_U16, T.T_I32, T.T_U32, T.T_I64, T.T_U64, T.T_Bool
+	I := util.IntLit
+	U := util.UintLit
+	tests := []struct {
+		kind   IT.InstrKind
+		t      *T.Type
+		dest   *T.Type
+		ops    []pir.Operand
+		result string // empty if it can't be folded
+	}{
+		{IT.Add, i64, i64, []pir.Operand{I(i64, 2), I(i64, 3)}, "5:i64"},
+		{IT.Add, i8, i8, []pir.Operand{I(i8, 127), I(i8, 1)}, "-128:i8"},
+		{IT.Add, u8, u8, []pir.Operand{U(u8, 255), U(u8, 1)}, "0:u8"},
+		{IT.Sub, u32, u32, []pir.Operand{U(u32, 0), U(u32, 1)}, "4294967295:u32"},
+		{IT.Sub, i64, i64, []pir.Operand{I(i64, -9223372036854775808), I(i64, 1)}, "9223372036854775807:i64"},
+		{IT.Mult, i32, i32, []pir.Operand{I(i32, 1<<30), I(i32, 4)}, "0:i32"},
+		{IT.Mult, i8, i8, []pir.Operand{I(i8, -3), I(i8, 5)}, "-15:i8"},
+
+		{IT.Div, i8, i8, []pir.Operand{I(i8, -7), I(i8, 2)}, "-3:i8"},
+		{IT.Rem, i8, i8, []pir.Operand{I(i8, -7), I(i8, 2)}, "-1:i8"},
+		{IT.Div, u8, u8, []pir.Operand{U(u8, 250), U(u8, 7)}, "35:u8"},
+		{IT.Rem, u8, u8, []pir.Operand{U(u8, 250), U(u8, 7)}, "5:u8"},
+		{IT.Div, u64, u64, []pir.Operand{U(u64, 1<<63), U(u64, 3)}, "3074457345618258602:u64"},
+		{IT.Div, i64, i64, []pir.Operand{I(i64, -9223372036854775808), I(i64, 3)}, "-3074457345618258602:i64"},
+		{IT.Div, i8, i8, []pir.Operand{I(i8, -128), I(i8, -1)}, ""},
+		{IT.Rem, i8, i8, []pir.Operand{I(i8, -128), I(i8, -1)}, ""},
+		{IT.Div, i64, i64, []pir.Operand{I(i64, 1), I(i64, 0)}, ""},
+		{IT.Rem, u32, u32, []pir.Operand{U(u32, 1), U(u32, 0)}, ""},
+		{IT.Div, u8, u8, []pir.Operand{U(u8, 128), U(u8, 255)}, "0:u8"},
+
+		{IT.ShiftLeft, i8, i8, []pir.Operand{I(i8, 1), I(i8, 7)}, "-128:i8"},
+		{IT.ShiftLeft, u8, u8, []pir.Operand{U(u8, 1), U(u8, 9)}, "0:u8"},
+		{IT.ShiftLeft, i64, i64, []pir.Operand{I(i64, 1), I(i64, 65)}, "2:i64"},
+		{IT.ShiftLeft, u32, u32, []pir.Operand{U(u32, 1), U(u32, 33)}, "2:u32"},
+		{IT.ShiftRight, i8, i8, []pir.Operand{I(i8, -128), I(i8, 3)}, "-16:i8"},
+		{IT.ShiftRight, u8, u8, []pir.Operand{U(u8, 128), U(u8, 3)}, "16:u8"},
+		{IT.ShiftRight, i32, i32, []pir.Operand{I(i32, -1), I(i32, 40)}, "-1:i32"},
+		{IT.ShiftRight, u64, u64, []pir.Operand{U(u64, 1<<63), U(u64, 63)}, "1:u64"},
+
+		{IT.Eq, b, b, []pir.Operand{util.BoolLit(true), util.BoolLit(true)}, "1:bool"},
+		{IT.Diff, i8, b, []pir.Operand{I(i8, -1), I(i8, -1)}, "0:bool"},
+		{IT.Less, u8, b, []pir.Operand{U(u8, 200), U(u8, 100)}, "0:bool"},
+		{IT.Less, i8, b, []pir.Operand{I(i8, -56), I(i8, 100)}, "1:bool"},
+		{IT.More, i64, b, []pir.Operand{I(i64, -1), I(i64, 0)}, "0:bool"},
+		{IT.More, u64, b, []pir.Operand{U(u64, 1<<64-1), U(u64, 0)}, "1:bool"},
+		{IT.MoreEq, u64, b, []pir.Operand{U(u64, 1<<63), U(u64, 1)}, "1:bool"},
+		{IT.LessEq, i32, b, []pir.Operand{I(i32, -2147483648), I(i32, -2147483648)}, "1:bool"},
+
+		{IT.And, u8, u8, []pir.Operand{U(u8, 0xf0), U(u8, 0x3c)}, "48:u8"},
+		{IT.Or, b, b, []pir.Operand{util.BoolLit(false), util.BoolLit(true)}, "1:bool"},
+		{IT.Xor, u16, u16, []pir.Operand{U(u16, 0xff00), U(u16, 0x0ff0)}, "61680:u16"},
+		{IT.Not, b, b, []pir.Operand{util.BoolLit(false)}, "1:bool"},
+		{IT.Not, u8, u8, []pir.Operand{U(u8, 0x0f)}, "240:u8"},
+		{IT.Not, i8, i8, []pir.Operand{I(i8, 0)}, "-1:i8"},
+		{IT.Neg, i8, i8, []pir.Operand{I(i8, -128)}, "-128:i8"},
+		{IT.Neg, i64, i64, []pir.Operand{I(i64, 5)}, "-5:i64"},
+
+		{IT.Convert, i64, i64, []pir.Operand{I(i8, -1)}, "-1:i64"},
+		{IT.Convert, u64, u64, []pir.Operand{I(i8, -1)}, "18446744073709551615:u64"},
+		{IT.Convert, i64, i64, []pir.Operand{U(u8, 255)}, "255:i64"},
+		{IT.Convert, u8, u8, []pir.Operand{I(i32, 300)}, "44:u8"},
+		{IT.Convert, i8, i8, []pir.Operand{U(u32, 200)}, "-56:i8"},
+		{IT.Convert, i32, i32, []pir.Operand{util.BoolLit(true)}, "1:i32"},
+		{IT.Convert, b, b, []pir.Operand{I(i32, 2)}, ""},
+
+		// operands that aren't literals
+		{IT.Add, i64, i64, []pir.Operand{I(i64, 1), {Class: hirc.Local, Num: 0, Type: i64}}, ""},
+		{IT.Add, i64, i64, []pir.Operand{I(i64, 1), {Class: hirc.Lit, Num: 0}}, ""},
+	}
+	for _, tt := range tests {
+		instr := pir.Instr{
+			T:           tt.kind,
+			Type:        tt.t,
+			Operands:    tt.ops,
+			Destination: []pir.Operand{{Class: hirc.Temp, Num: 0, Type: tt.dest}},
+		}
+		lit, ok := Fold(instr)
+		result := ""
+		if ok {
+			result = printer.Operand(nil, lit)
+		}
+		if result != tt.result {
+			t.Errorf("%v: got %q, expected %q", instr.String(), result, tt.result)
+		}
+	}
+}
+
+func TestProcedure(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		changed bool
+		output  string
+	}{
+		{
+			"propagation",
+			`Program: x
+
+main{
+
+
+i64, i64
+}:
+b0:
+	add:i64 2, 3 -> local#0:i64
+	less:i64 local#0:i64, 10 -> '0:bool
+	if '0:bool? .L1 : .L2
+b1:
+	mult:i64 local#0:i64, 2 -> local#1:i64
+	jmp .L2
+b2:
+	convert:i8 local#0:i64 -> '0:i8
+	exit '0:i8
+`,
+			true,
+			`proc main proc[][]
+	vars i64, i64
+	start .L0
+.L0 b0:
+	copy:i64 5:i64 -> local#0:i64
+	copy:bool 1:bool -> '0:bool
+	jmp .L1
+.L1 b1:
+	copy:i64 10:i64 -> local#1:i64
+	jmp .L2
+.L2 b2:
+	copy:i8 5:i8 -> '0:i8
+	exit 5:i8
+`,
+		},
+		{
+			// local#0 is 1 or 2 at b3, so it's not replaced there
+			"disagreement",
+			`Program: x
+
+f{
+bool
+i64
+i64
+}:
+b0:
+	if arg#0:bool? .L1 : .L2
+b1:
+	copy:i64 1 -> local#0:i64
+	jmp .L3
+b2:
+	copy:i64 2 -> local#0:i64
+	jmp .L3
+b3:
+	add:i64 local#0:i64, 1 -> local#0:i64
+	ret local#0:i64
+`,
+			false,
+			`proc f proc[bool][i64]
+	vars i64
+	start .L0
+.L0 b0:
+	if arg#0:bool ? .L1 : .L2
+.L1 b1:
+	copy:i64 1:i64 -> local#0:i64
+	jmp .L3
+.L2 b2:
+	copy:i64 2:i64 -> local#0:i64
+	jmp .L3
+.L3 b3:
+	add:i64 local#0:i64, 1:i64 -> local#0:i64
+	ret local#0:i64
+`,
+		},
+	}
+	for _, tt := range tests {
+		P, err := parse.Program(tt.input)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		proc := P.Symbols[0].Proc
+		if changed := Procedure(proc); changed != tt.changed {
+			t.Errorf("%v: Procedure returned %v, expected %v", tt.name, changed, tt.changed)
+		}
+		var output strings.Builder
+		printer.Procedure(&output, P, proc)
+		if output.String() != tt.output {
+			t.Errorf("%v: got:\n%v\nexpected:\n%v", tt.name, output.String(), tt.output)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package dce
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+	hirc "github.com/padeir0/pir/class"
+	FT "github.com/padeir0/pir/flowkind"
+	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/liveness"
+	T "github.com/padeir0/pir/types"
+)
+
+/*
+Dead code elimination.
+
+Blocks removes the blocks that can't be reached from the start block,
+they appear after folding conditions and the checker rejects them.
+Instrs removes instructions without side effects whose results are
+never read. Calls, stores and loads are always kept, and so are
+divisions that may fault: by anything but a literal, by 0, or, for
+signed types, by -1.
+
+The procedure must be valid, see checker.Check.
+*/
+
+// Blocks removes the unreachable blocks of proc and renumbers the rest,
+// it reports whether anything was removed.
+func Blocks(proc *pir.Procedure) bool {
+	g := cfg.New(proc)
+	newID := make([]pir.BlockID, len(proc.AllBlocks))
+	kept := []*pir.BasicBlock{}
+	for i, bb := range proc.AllBlocks {
+		if bb == nil || !g.Reachable[i] {
+			newID[i] = -1
+			continue
+		}
+		newID[i] = pir.BlockID(len(kept))
+		kept = append(kept, bb)
+	}
+	if len(kept) == len(proc.AllBlocks) {
+		return false
+	}
+	for _, bb := range kept {
+		switch bb.Out.T {
+		case FT.Jmp:
+			bb.Out.True = newID[bb.Out.True]
+		case FT.If:
+			bb.Out.True = newID[bb.Out.True]
+			bb.Out.False = newID[bb.Out.False]
+		}
+	}
+	proc.Start = newID[proc.Start]
+	proc.AllBlocks = kept
+	return true
+}
+
+// Instrs removes the dead instructions of proc, live must be up to date.
+// Blocks are swept backwards, so chains of dead instructions inside a
+// block are removed at once, but values that were only read in other
+// blocks are still live: it may be worth running again.
+func Instrs(proc *pir.Procedure, live *liveness.Liveness) bool {
+	changed := false
+	for id, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		current := live.Out[id].Copy()
+		use(current, bb.Out.V)
+		kept := []pir.Instr{}
+		for i := len(bb.Code) - 1; i >= 0; i-- {
+			instr := bb.Code[i]
+			if isPure(instr) && !anyLive(instr.Destination, current) {
+				continue
+			}
+			for _, dest := range instr.Destination {
+				if v, ok := liveness.ValueOf(dest); ok {
+					delete(current, v)
+				}
+			}
+			use(current, instr.Operands)
+			kept = append(kept, instr)
+		}
+		if len(kept) == len(bb.Code) {
+			continue
+		}
+		changed = true
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+		bb.Code = kept
+	}
+	return changed
+}
+
+func isPure(instr pir.Instr) bool {
+	switch instr.T {
+	case IT.Call, IT.StorePtr, IT.LoadPtr:
+		return false
+	case IT.Div, IT.Rem:
+		if len(instr.Operands) < 2 {
+			return false
+		}
+		// signed literals are sign extended, MinInt / -1 faults too
+		divisor := instr.Operands[1]
+		if divisor.Class != hirc.Lit || divisor.Num == 0 {
+			return false
+		}
+		return !(T.IsInt(instr.Type) && int64(divisor.Num) == -1)
+	}
+	return len(instr.Destination) > 0
+}
+
+func anyLive(dests []pir.Operand, current liveness.Set) bool {
+	for _, dest := range dests {
+		v, ok := liveness.ValueOf(dest)
+		if !ok || current.Has(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func use(current liveness.Set, ops []pir.Operand) {
+	for _, op := range ops {
+		if v, ok := liveness.ValueOf(op); ok {
+			current[v] = true
+		}
+	}
+}
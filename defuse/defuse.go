@@ -0,0 +1,365 @@
+package defuse
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+	hirc "github.com/padeir0/pir/class"
+	"github.com/padeir0/pir/liveness"
+
+	"strconv"
+)
+
+/*
+Def-use and use-def chains of temps, locals and arguments.
+
+Chains are built from reaching definitions, so they're correct for
+values written more than once. Arguments and locals are also defined at
+the entry of the procedure, by the caller or, for locals, left
+uninitialized: that definition is the Entry site.
+
+Chains are computed lazily, edits made outside of this package must be
+followed by Invalidate, and the next query recomputes them.
+*/
+
+// Site is the position of an operand in a procedure.
+// For definitions, Operand indexes the Destination of the instruction,
+// for uses it indexes the Operands, or the Flow.V if Index is len(Code).
+type Site struct {
+	Block   pir.BlockID
+	Index   int
+	Operand int
+}
+
+// Entry is the definition of arguments and locals at procedure entry.
+var Entry = Site{Block: -1, Index: -1, Operand: -1}
+
+func (s Site) String() string {
+	if s == Entry {
+		return "entry"
+	}
+	return ".L" + strconv.Itoa(int(s.Block)) + "#" + strconv.Itoa(s.Index) + ":" + strconv.Itoa(s.Operand)
+}
+
+type Chains struct {
+	proc  *pir.Procedure
+	valid bool
+
+	defs map[liveness.Value][]Site
+	uses map[liveness.Value][]Site
+	// definitions reaching each use, in order of definition
+	useDef map[Site][]Site
+	// uses reached by each definition, in program order
+	defUse    map[Site][]Site
+	entryUses map[liveness.Value][]Site
+}
+
+// New computes the chains of proc.
+func New(proc *pir.Procedure) *Chains {
+	c := &Chains{proc: proc}
+	c.compute()
+	return c
+}
+
+// Invalidate marks the chains as stale, they're recomputed on the next query.
+func (this *Chains) Invalidate() {
+	this.valid = false
+}
+
+func (this *Chains) Valid() bool {
+	return this.valid
+}
+
+// Defs returns the definitions of v, in program order, without Entry.
+// Temps are block-local, so the same temp may name
+// unrelated values in different blocks.
+func (this *Chains) Defs(v liveness.Value) []Site {
+	this.update()
+	return this.defs[v]
+}
+
+// Uses returns the uses of v, in program order.
+func (this *Chains) Uses(v liveness.Value) []Site {
+	this.update()
+	return this.uses[v]
+}
+
+// Reaching returns the definitions that reach the use,
+// Entry is included if the value may come from procedure entry.
+func (this *Chains) Reaching(use Site) []Site {
+	this.update()
+	return this.useDef[use]
+}
+
+// UsesOf returns the uses reached by the definition, in program order.
+func (this *Chains) UsesOf(def Site) []Site {
+	this.update()
+	return this.defUse[def]
+}
+
+// EntryUses returns the uses of v reached by its value at procedure entry.
+func (this *Chains) EntryUses(v liveness.Value) []Site {
+	this.update()
+	return this.entryUses[v]
+}
+
+// Def returns the operand defined at the site.
+func (this *Chains) Def(s Site) pir.Operand {
+	return this.proc.AllBlocks[s.Block].Code[s.Index].Destination[s.Operand]
+}
+
+// Use returns the operand used at the site.
+func (this *Chains) Use(s Site) pir.Operand {
+	return *this.usePtr(s)
+}
+
+// ReplaceAllUsesWith replaces every use reached only by def with op,
+// and returns how many were replaced. Uses also reached by other
+// definitions are left untouched. Replacing with a literal or a global
+// keeps the chains up to date, replacing with another value invalidates them.
+func (this *Chains) ReplaceAllUsesWith(def Site, op pir.Operand) int {
+	this.update()
+	replaced := 0
+	kept := []Site{}
+	for _, use := range this.defUse[def] {
+		if len(this.useDef[use]) != 1 {
+			kept = append(kept, use)
+			continue
+		}
+		ptr := this.usePtr(use)
+		v, _ := liveness.ValueOf(*ptr)
+		*ptr = op
+		this.uses[v] = remove(this.uses[v], use)
+		delete(this.useDef, use)
+		replaced++
+	}
+	this.defUse[def] = kept
+	if _, ok := liveness.ValueOf(op); ok && replaced > 0 {
+		this.Invalidate()
+	}
+	return replaced
+}
+
+func (this *Chains) usePtr(s Site) *pir.Operand {
+	bb := this.proc.AllBlocks[s.Block]
+	if s.Index == len(bb.Code) {
+		return &bb.Out.V[s.Operand]
+	}
+	return &bb.Code[s.Index].Operands[s.Operand]
+}
+
+func (this *Chains) update() {
+	if !this.valid {
+		this.compute()
+	}
+}
+
+type definition struct {
+	value liveness.Value
+	site  Site
+}
+
+type analysis struct {
+	proc   *pir.Procedure
+	defs   []definition
+	defsOf map[liveness.Value][]int
+	// index of each definition site, Entry is per value
+	index map[Site]int
+}
+
+func (this *Chains) compute() {
+	proc := this.proc
+	a := &analysis{
+		proc:   proc,
+		defsOf: map[liveness.Value][]int{},
+		index:  map[Site]int{},
+	}
+	numberDefs(a)
+
+	g := cfg.New(proc)
+	n := len(proc.AllBlocks)
+	in := make([]bitset, n)
+	out := make([]bitset, n)
+	for i := 0; i < n; i++ {
+		in[i] = newBitset(len(a.defs))
+		out[i] = newBitset(len(a.defs))
+	}
+	entry := newBitset(len(a.defs))
+	for i, d := range a.defs {
+		if d.site == Entry {
+			entry.Set(i)
+		}
+	}
+	order := append([]pir.BlockID{}, g.ReversePostOrder...)
+	for i := 0; i < n; i++ {
+		if !g.Reachable[i] {
+			order = append(order, pir.BlockID(i))
+		}
+	}
+	changed := true
+	for changed {
+		changed = false
+		for _, id := range order {
+			bb := proc.AllBlocks[id]
+			if bb == nil {
+				continue
+			}
+			state := newBitset(len(a.defs))
+			if id == g.Start {
+				state.Union(entry)
+			}
+			for _, pred := range g.Preds[id] {
+				state.Union(out[pred])
+			}
+			in[id] = state.Copy()
+			transfer(a, id, state, nil)
+			if !state.Equals(out[id]) {
+				out[id] = state
+				changed = true
+			}
+		}
+	}
+
+	this.defs = map[liveness.Value][]Site{}
+	this.uses = map[liveness.Value][]Site{}
+	this.useDef = map[Site][]Site{}
+	this.defUse = map[Site][]Site{}
+	this.entryUses = map[liveness.Value][]Site{}
+	for _, d := range a.defs {
+		if d.site != Entry {
+			this.defs[d.value] = append(this.defs[d.value], d.site)
+		}
+	}
+	for id, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		transfer(a, pir.BlockID(id), in[id], this)
+	}
+	this.valid = true
+}
+
+// definitions are numbered in program order, after the ones at entry
+func numberDefs(a *analysis) {
+	for i := range a.proc.Args {
+		addDef(a, liveness.Value{Class: hirc.Arg, Num: uint64(i)}, Entry)
+	}
+	for i := range a.proc.Vars {
+		addDef(a, liveness.Value{Class: hirc.Local, Num: uint64(i)}, Entry)
+	}
+	for id, bb := range a.proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		for i, instr := range bb.Code {
+			for j, dest := range instr.Destination {
+				if v, ok := liveness.ValueOf(dest); ok {
+					addDef(a, v, Site{Block: pir.BlockID(id), Index: i, Operand: j})
+				}
+			}
+		}
+	}
+}
+
+func addDef(a *analysis, v liveness.Value, s Site) {
+	i := len(a.defs)
+	a.defs = append(a.defs, definition{value: v, site: s})
+	a.defsOf[v] = append(a.defsOf[v], i)
+	if s != Entry {
+		a.index[s] = i
+	}
+}
+
+// walks the block forward, updating the reaching definitions in state.
+// If c is not nil, the chains of each use are recorded in it.
+func transfer(a *analysis, id pir.BlockID, state bitset, c *Chains) {
+	bb := a.proc.AllBlocks[id]
+	for i, instr := range bb.Code {
+		if c != nil {
+			recordUses(a, c, state, id, i, instr.Operands)
+		}
+		for j, dest := range instr.Destination {
+			v, ok := liveness.ValueOf(dest)
+			if !ok {
+				continue
+			}
+			for _, d := range a.defsOf[v] {
+				state.Clear(d)
+			}
+			state.Set(a.index[Site{Block: id, Index: i, Operand: j}])
+		}
+	}
+	if c != nil {
+		recordUses(a, c, state, id, len(bb.Code), bb.Out.V)
+	}
+}
+
+func recordUses(a *analysis, c *Chains, state bitset, id pir.BlockID, index int, ops []pir.Operand) {
+	for j, op := range ops {
+		v, ok := liveness.ValueOf(op)
+		if !ok {
+			continue
+		}
+		use := Site{Block: id, Index: index, Operand: j}
+		c.uses[v] = append(c.uses[v], use)
+		reaching := []Site{}
+		for _, d := range a.defsOf[v] {
+			if !state.Has(d) {
+				continue
+			}
+			def := a.defs[d].site
+			reaching = append(reaching, def)
+			if def == Entry {
+				c.entryUses[v] = append(c.entryUses[v], use)
+			} else {
+				c.defUse[def] = append(c.defUse[def], use)
+			}
+		}
+		c.useDef[use] = reaching
+	}
+}
+
+func remove(sites []Site, s Site) []Site {
+	for i, other := range sites {
+		if other == s {
+			return append(sites[:i:i], sites[i+1:]...)
+		}
+	}
+	return sites
+}
+
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) Set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b bitset) Clear(i int) {
+	b[i/64] &^= 1 << uint(i%64)
+}
+
+func (b bitset) Has(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (b bitset) Union(other bitset) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+func (b bitset) Copy() bitset {
+	return append(bitset{}, b...)
+}
+
+func (b bitset) Equals(other bitset) bool {
+	for i := range b {
+		if b[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,281 @@
+package dom
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+)
+
+/*
+Dominator and post-dominator trees, computed with the algorithm of
+Cooper, Harvey and Kennedy ("A Simple, Fast Dominance Algorithm").
+
+Post-dominators are the dominators of the reversed CFG, with a virtual
+exit that precedes every Return and Exit block, so a procedure with many
+exits has a forest instead of a tree. Blocks that are not reachable
+(or, for post-dominators, that never reach an exit) are not in the tree.
+*/
+type Tree struct {
+	// immediate dominator of each block,
+	// -1 for the roots and for blocks not in the tree
+	Idom     []pir.BlockID
+	Children [][]pir.BlockID
+	// the start block for dominators, the exits for post-dominators
+	Roots []pir.BlockID
+	// dominance frontier of each block, for post-dominators
+	// this is the set of blocks each block is control dependent on.
+	// The start block is in the frontier of the blocks of any cycle
+	// through it, including its own.
+	Frontier [][]pir.BlockID
+
+	// every block in the tree, parents before children
+	PreOrder []pir.BlockID
+
+	// numbering of a DFS over the tree, -1 if not in the tree
+	enter []int
+	exit  []int
+}
+
+// New computes the dominator tree of proc.
+func New(proc *pir.Procedure) *Tree {
+	g := cfg.New(proc)
+	n := len(proc.AllBlocks)
+	if g.Start < 0 || int(g.Start) >= n {
+		return newTree(n, nil, -1, nil)
+	}
+	idom := compute(n, g.Start, g.Succs, g.Preds)
+	return newTree(n, idom, g.Start, g.Preds)
+}
+
+// NewPost computes the post-dominator tree of proc.
+func NewPost(proc *pir.Procedure) *Tree {
+	g := cfg.New(proc)
+	n := len(proc.AllBlocks)
+	virtual := pir.BlockID(n)
+	// the reversed graph, with the virtual exit as the root
+	succs := make([][]pir.BlockID, n+1)
+	preds := make([][]pir.BlockID, n+1)
+	for i := 0; i < n; i++ {
+		succs[i] = g.Preds[i]
+		preds[i] = g.Succs[i]
+	}
+	succs[virtual] = g.Exits
+	for _, id := range g.Exits {
+		preds[id] = append(preds[id], virtual)
+	}
+	idom := compute(n+1, virtual, succs, preds)
+	return newTree(n, idom, virtual, preds)
+}
+
+// Dominates reports whether every path from the root to b
+// goes through a, a block dominates itself.
+func (this *Tree) Dominates(a, b pir.BlockID) bool {
+	if !this.InTree(a) || !this.InTree(b) {
+		return false
+	}
+	return this.enter[a] <= this.enter[b] && this.exit[b] <= this.exit[a]
+}
+
+// StrictlyDominates is the same as Dominates, but a != b.
+func (this *Tree) StrictlyDominates(a, b pir.BlockID) bool {
+	return a != b && this.Dominates(a, b)
+}
+
+func (this *Tree) InTree(id pir.BlockID) bool {
+	return id >= 0 && int(id) < len(this.enter) && this.enter[id] >= 0
+}
+
+const undefined pir.BlockID = -1
+
+// returns the immediate dominator of each node, undefined if unreachable,
+// the root is its own immediate dominator
+func compute(n int, root pir.BlockID, succs, preds [][]pir.BlockID) []pir.BlockID {
+	order := postOrder(n, root, succs)
+	// position of each node in the post-order, -1 if unreachable
+	number := make([]int, n)
+	for i := range number {
+		number[i] = -1
+	}
+	for i, id := range order {
+		number[id] = i
+	}
+	idom := make([]pir.BlockID, n)
+	for i := range idom {
+		idom[i] = undefined
+	}
+	idom[root] = root
+
+	changed := true
+	for changed {
+		changed = false
+		// reverse post-order, skipping the root
+		for i := len(order) - 2; i >= 0; i-- {
+			b := order[i]
+			newIdom := undefined
+			for _, p := range preds[b] {
+				if idom[p] == undefined {
+					continue
+				}
+				if newIdom == undefined {
+					newIdom = p
+				} else {
+					newIdom = intersect(idom, number, p, newIdom)
+				}
+			}
+			if idom[b] != newIdom {
+				idom[b] = newIdom
+				changed = true
+			}
+		}
+	}
+	return idom
+}
+
+func intersect(idom []pir.BlockID, number []int, a, b pir.BlockID) pir.BlockID {
+	for a != b {
+		for number[a] < number[b] {
+			a = idom[a]
+		}
+		for number[b] < number[a] {
+			b = idom[b]
+		}
+	}
+	return a
+}
+
+func postOrder(n int, root pir.BlockID, succs [][]pir.BlockID) []pir.BlockID {
+	type frame struct {
+		id   pir.BlockID
+		next int
+	}
+	visited := make([]bool, n)
+	output := []pir.BlockID{}
+	stack := []frame{{id: root}}
+	visited[root] = true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(succs[top.id]) {
+			succ := succs[top.id][top.next]
+			top.next++
+			if !visited[succ] {
+				visited[succ] = true
+				stack = append(stack, frame{id: succ})
+			}
+			continue
+		}
+		output = append(output, top.id)
+		stack = stack[:len(stack)-1]
+	}
+	return output
+}
+
+// the first n nodes are blocks, nodes after that are virtual,
+// and so are never part of the result
+func newTree(n int, idom []pir.BlockID, root pir.BlockID, preds [][]pir.BlockID) *Tree {
+	t := &Tree{
+		Idom:     make([]pir.BlockID, n),
+		Children: make([][]pir.BlockID, n),
+		Frontier: make([][]pir.BlockID, n),
+		Roots:    []pir.BlockID{},
+		PreOrder: []pir.BlockID{},
+		enter:    make([]int, n),
+		exit:     make([]int, n),
+	}
+	for i := 0; i < n; i++ {
+		t.Idom[i] = -1
+		t.enter[i] = -1
+		t.exit[i] = -1
+	}
+	if idom == nil {
+		return t
+	}
+	for i := 0; i < n; i++ {
+		b := pir.BlockID(i)
+		d := idom[b]
+		switch {
+		case d == undefined:
+		case b == root || d == root && int(root) >= n:
+			t.Roots = append(t.Roots, b)
+		default:
+			t.Idom[b] = d
+			t.Children[d] = append(t.Children[d], b)
+		}
+	}
+	number(t)
+	frontiers(t, idom, preds, root, n)
+	return t
+}
+
+// fills PreOrder, enter and exit
+func number(t *Tree) {
+	type frame struct {
+		id   pir.BlockID
+		next int
+	}
+	clock := 0
+	for _, root := range t.Roots {
+		stack := []frame{{id: root}}
+		t.enter[root] = clock
+		t.PreOrder = append(t.PreOrder, root)
+		clock++
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			children := t.Children[top.id]
+			if top.next < len(children) {
+				child := children[top.next]
+				top.next++
+				t.enter[child] = clock
+				t.PreOrder = append(t.PreOrder, child)
+				clock++
+				stack = append(stack, frame{id: child})
+				continue
+			}
+			t.exit[top.id] = clock
+			clock++
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func frontiers(t *Tree, idom []pir.BlockID, preds [][]pir.BlockID, root pir.BlockID, n int) {
+	for i := 0; i < n; i++ {
+		b := pir.BlockID(i)
+		if idom[b] == undefined {
+			continue
+		}
+		// the root is also entered from outside the procedure,
+		// so a single edge back to it already makes it a join
+		joins := len(preds[b])
+		if b == root {
+			joins++
+		}
+		if joins < 2 {
+			continue
+		}
+		for _, p := range preds[b] {
+			if idom[p] == undefined {
+				continue
+			}
+			runner := p
+			for runner != idom[b] {
+				if int(runner) < n {
+					addFrontier(t, runner, b)
+				}
+				runner = idom[runner]
+			}
+			// the root is its own immediate dominator,
+			// so the walk stops right before it
+			if b == root {
+				addFrontier(t, root, b)
+			}
+		}
+	}
+}
+
+func addFrontier(t *Tree, id, b pir.BlockID) {
+	for _, other := range t.Frontier[id] {
+		if other == b {
+			return
+		}
+	}
+	t.Frontier[id] = append(t.Frontier[id], b)
+}
@@ -0,0 +1,143 @@
+package dom
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/util"
+
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// blocks without successors return, blocks with one jump
+// and blocks with two branch
+func proc(succs ...[]pir.BlockID) *pir.Procedure {
+	cond := util.BoolLit(true)
+	bbs := make([]*pir.BasicBlock, len(succs))
+	for i, s := range succs {
+		bbs[i] = &pir.BasicBlock{Label: "b" + strconv.Itoa(i)}
+		switch len(s) {
+		case 0:
+			bbs[i].Out = util.Return()
+		case 1:
+			bbs[i].Out = util.Jmp(s[0])
+		default:
+			bbs[i].Out = util.Branch(cond, s[0], s[1])
+		}
+	}
+	return &pir.Procedure{Label: "p", AllBlocks: bbs}
+}
+
+func ids(list ...pir.BlockID) []pir.BlockID { return list }
+
+// 0 -> 1 ; 1 -> 2 | 3 ; 2 -> 4 ; 3 -> 4 | 7 ; 4 -> 1 | 5 ; 5 returns
+// 6 -> 5 is unreachable and 7 is an infinite loop
+var classic = proc(ids(1), ids(2, 3), ids(4), ids(4, 7), ids(1, 5), ids(), ids(5), ids(7))
+
+// 0 -> 1 | 3 ; 1 -> 2 ; 2 -> 1 | 3 ; 3 returns ; 4 -> 3 is unreachable
+var loop = proc(ids(1, 3), ids(2), ids(1, 3), ids(), ids(3))
+
+// both 1 and 2 are entered from 0
+var irreducible = proc(ids(1, 2), ids(2), ids(1, 3), ids())
+
+// 0 -> 1 ; 1 -> 0 | 2 ; 2 returns
+var rootLoop = proc(ids(1), ids(0, 2), ids())
+
+// 0 -> 0 | 1 ; 1 returns
+var selfLoop = proc(ids(0, 1), ids())
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		proc     *pir.Procedure
+		idom     string
+		frontier string
+		preOrder string
+	}{
+		{"classic", classic, "[-1 0 1 1 1 4 -1 3]", "[[] [1] [4] [4] [1] [] [] [7]]", "[0 1 2 3 7 4 5]"},
+		{"loop", loop, "[-1 0 1 0 -1]", "[[] [1 3] [1 3] [] []]", "[0 1 2 3]"},
+		{"irreducible", irreducible, "[-1 0 0 2]", "[[] [2] [1] []]", "[0 1 2 3]"},
+		{"loop through the start", rootLoop, "[-1 0 1]", "[[0] [0] []]", "[0 1 2]"},
+		{"self loop on the start", selfLoop, "[-1 0]", "[[0] []]", "[0 1]"},
+	}
+	for _, tt := range tests {
+		d := New(tt.proc)
+		check := func(field, got, expected string) {
+			if got != expected {
+				t.Errorf("%v: %v is %v, expected %v", tt.name, field, got, expected)
+			}
+		}
+		check("Idom", fmt.Sprint(d.Idom), tt.idom)
+		check("Frontier", fmt.Sprint(d.Frontier), tt.frontier)
+		check("PreOrder", fmt.Sprint(d.PreOrder), tt.preOrder)
+		check("Roots", fmt.Sprint(d.Roots), "[0]")
+	}
+}
+
+func TestNewPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		proc     *pir.Procedure
+		idom     string
+		frontier string
+		roots    string
+	}{
+		{"classic", classic, "[1 4 4 4 5 -1 5 -1]", "[[] [4] [1] [1] [4] [] [] []]", "[5]"},
+		{"loop", loop, "[3 2 3 -1 3]", "[[] [0 2] [0 2] [] []]", "[3]"},
+		{"irreducible", irreducible, "[2 2 3 -1]", "[[] [0 2] [2] []]", "[3]"},
+		{"loop through the start", rootLoop, "[1 2 -1]", "[[1] [1] []]", "[2]"},
+		{"self loop on the start", selfLoop, "[1 -1]", "[[0] []]", "[1]"},
+	}
+	for _, tt := range tests {
+		d := NewPost(tt.proc)
+		check := func(field, got, expected string) {
+			if got != expected {
+				t.Errorf("%v: %v is %v, expected %v", tt.name, field, got, expected)
+			}
+		}
+		check("Idom", fmt.Sprint(d.Idom), tt.idom)
+		check("Frontier", fmt.Sprint(d.Frontier), tt.frontier)
+		check("Roots", fmt.Sprint(d.Roots), tt.roots)
+	}
+}
+
+func TestDominates(t *testing.T) {
+	d := New(classic)
+	p := NewPost(classic)
+	tests := []struct {
+		tree     *Tree
+		a, b     pir.BlockID
+		expected bool
+	}{
+		{d, 1, 4, true},
+		{d, 2, 4, false},
+		{d, 0, 7, true},
+		{d, 4, 4, true},
+		{d, 6, 5, false}, // 6 is unreachable
+		{d, 0, 6, false},
+		{p, 4, 2, true},
+		{p, 5, 0, true},
+		{p, 5, 7, false}, // 7 never reaches an exit
+		{p, 2, 1, false},
+	}
+	for _, tt := range tests {
+		if got := tt.tree.Dominates(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Dominates(%v, %v) is %v, expected %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+	if d.StrictlyDominates(4, 4) || !d.StrictlyDominates(1, 4) {
+		t.Errorf("StrictlyDominates is wrong")
+	}
+	if d.InTree(6) || !d.InTree(7) || p.InTree(7) {
+		t.Errorf("InTree is wrong")
+	}
+}
+
+func TestStartOutOfBounds(t *testing.T) {
+	p := proc(ids())
+	p.Start = 3
+	d := New(p)
+	if len(d.Roots) != 0 || d.InTree(0) || d.Dominates(0, 0) {
+		t.Errorf("no block should be in the tree")
+	}
+}
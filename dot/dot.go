@@ -0,0 +1,277 @@
+package dot
+
+import (
+	"github.com/padeir0/pir"
+	mir "github.com/padeir0/pir/backends/linuxamd64/mir"
+	mirc "github.com/padeir0/pir/backends/linuxamd64/mir/class"
+	mirFT "github.com/padeir0/pir/backends/linuxamd64/mir/flowkind"
+	mirIT "github.com/padeir0/pir/backends/linuxamd64/mir/instrkind"
+	"github.com/padeir0/pir/callgraph"
+	FT "github.com/padeir0/pir/flowkind"
+	"github.com/padeir0/pir/printer"
+
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+Renders control flow graphs in the Graphviz DOT language.
+
+Each basic block is a record node with its label, instructions and flow,
+the start block is drawn in bold. Edges are labelled true, false or jmp.
+Block IDs that are out of bounds are still drawn as edges,
+so that malformed procedures can be inspected.
+*/
+
+type Options struct {
+	// emit the call graph of the program instead of
+	// the control flow graph of each procedure
+	CallGraph bool
+}
+
+// Program writes the control flow graph of every procedure in P
+// as a cluster of a single digraph, or the call graph if opts.CallGraph is set.
+func Program(w io.Writer, P *pir.Program, opts Options) error {
+	g := &graph{w: w}
+	g.Line("digraph " + quote(P.Name) + " {")
+	if opts.CallGraph {
+		hirCallGraph(g, P)
+	} else {
+		g.Line("\tnode [shape=record fontname=monospace];")
+		for i, sy := range P.Symbols {
+			if sy == nil || sy.Proc == nil || sy.Builtin || sy.Extern {
+				continue
+			}
+			g.Line("\tsubgraph " + quote("cluster_"+strconv.Itoa(i)) + " {")
+			g.Line("\t\tlabel=" + quote(sy.Proc.Label) + ";")
+			hirProcedure(g, P, sy.Proc, sy.Proc.Label, "\t\t")
+			g.Line("\t}")
+		}
+	}
+	g.Line("}")
+	return g.err
+}
+
+// Procedure writes the control flow graph of proc as a digraph.
+func Procedure(w io.Writer, P *pir.Program, proc *pir.Procedure) error {
+	g := &graph{w: w}
+	g.Line("digraph " + quote(proc.Label) + " {")
+	g.Line("\tnode [shape=record fontname=monospace];")
+	hirProcedure(g, P, proc, "", "\t")
+	g.Line("}")
+	return g.err
+}
+
+// MirProgram is the same as Program, but for MIR.
+func MirProgram(w io.Writer, P *mir.Program, opts Options) error {
+	g := &graph{w: w}
+	g.Line("digraph " + quote(P.Name) + " {")
+	if opts.CallGraph {
+		mirCallGraph(g, P)
+	} else {
+		g.Line("\tnode [shape=record fontname=monospace];")
+		for i, sy := range P.Symbols {
+			if sy == nil || sy.Proc == nil || sy.Builtin {
+				continue
+			}
+			g.Line("\tsubgraph " + quote("cluster_"+strconv.Itoa(i)) + " {")
+			g.Line("\t\tlabel=" + quote(sy.Proc.Label) + ";")
+			mirProcedure(g, sy.Proc, sy.Proc.Label, "\t\t")
+			g.Line("\t}")
+		}
+	}
+	g.Line("}")
+	return g.err
+}
+
+// MirProcedure is the same as Procedure, but for MIR.
+func MirProcedure(w io.Writer, proc *mir.Procedure) error {
+	g := &graph{w: w}
+	g.Line("digraph " + quote(proc.Label) + " {")
+	g.Line("\tnode [shape=record fontname=monospace];")
+	mirProcedure(g, proc, "", "\t")
+	g.Line("}")
+	return g.err
+}
+
+type graph struct {
+	w   io.Writer
+	err error
+}
+
+// after the first error, nothing else is written
+func (g *graph) Line(s string) {
+	if g.err != nil {
+		return
+	}
+	_, g.err = io.WriteString(g.w, s+"\n")
+}
+
+func hirProcedure(g *graph, P *pir.Program, proc *pir.Procedure, prefix, indent string) {
+	for i, bb := range proc.AllBlocks {
+		id := node(prefix, i)
+		if bb == nil {
+			g.Line(indent + id + " [label=\"nil\"];")
+			continue
+		}
+		lines := make([]string, len(bb.Code))
+		for j, instr := range bb.Code {
+			lines[j] = printer.Instr(P, instr)
+		}
+		label := record(blockName(i, bb.Label), lines, printer.Flow(P, bb.Out))
+		g.Line(indent + id + " [label=" + label + style(i == int(proc.Start)) + "];")
+	}
+	for i, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		id := node(prefix, i)
+		switch bb.Out.T {
+		case FT.Jmp:
+			edge(g, indent, id, node(prefix, int(bb.Out.True)), "jmp")
+		case FT.If:
+			edge(g, indent, id, node(prefix, int(bb.Out.True)), "true")
+			edge(g, indent, id, node(prefix, int(bb.Out.False)), "false")
+		}
+	}
+}
+
+func mirProcedure(g *graph, proc *mir.Procedure, prefix, indent string) {
+	for i, bb := range proc.AllBlocks {
+		id := node(prefix, i)
+		if bb == nil {
+			g.Line(indent + id + " [label=\"nil\"];")
+			continue
+		}
+		lines := make([]string, len(bb.Code))
+		for j, instr := range bb.Code {
+			lines[j] = instr.String()
+		}
+		label := record(blockName(i, bb.Label), lines, bb.Out.String())
+		g.Line(indent + id + " [label=" + label + style(i == int(proc.Start)) + "];")
+	}
+	for i, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		id := node(prefix, i)
+		switch bb.Out.T {
+		case mirFT.Jmp:
+			edge(g, indent, id, node(prefix, int(bb.Out.True)), "jmp")
+		case mirFT.If:
+			edge(g, indent, id, node(prefix, int(bb.Out.True)), "true")
+			edge(g, indent, id, node(prefix, int(bb.Out.False)), "false")
+		}
+	}
+}
+
+func hirCallGraph(g *graph, P *pir.Program) {
+	g.Line("\tnode [shape=box fontname=monospace];")
+	for i, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil {
+			continue
+		}
+		g.Line("\t" + symNode(i) + " [label=" + quote(sy.Proc.Label) + callStyle(sy.Builtin || sy.Extern, i == int(P.Entry)) + "];")
+	}
+	// indirect calls are dotted
+	for _, e := range callgraph.New(P).Edges {
+		attrs := ""
+		if e.Indirect {
+			attrs = " [style=dotted]"
+		}
+		g.Line("\t" + symNode(int(e.From)) + " -> " + symNode(int(e.To)) + attrs + ";")
+	}
+}
+
+func mirCallGraph(g *graph, P *mir.Program) {
+	g.Line("\tnode [shape=box fontname=monospace];")
+	for i, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil {
+			continue
+		}
+		g.Line("\t" + symNode(i) + " [label=" + quote(sy.Proc.Label) + callStyle(sy.Builtin, i == int(P.Entry)) + "];")
+	}
+	for i, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil || sy.Builtin {
+			continue
+		}
+		seen := map[uint64]bool{}
+		for _, bb := range sy.Proc.AllBlocks {
+			if bb == nil {
+				continue
+			}
+			for _, instr := range bb.Code {
+				if instr.T != mirIT.Call || !instr.A.Valid {
+					continue
+				}
+				if instr.A.Class != mirc.Static || seen[instr.A.Num] {
+					continue
+				}
+				seen[instr.A.Num] = true
+				g.Line("\t" + symNode(i) + " -> " + symNode(int(instr.A.Num)) + ";")
+			}
+		}
+	}
+}
+
+func edge(g *graph, indent, from, to, label string) {
+	g.Line(indent + from + " -> " + to + " [label=" + quote(label) + "];")
+}
+
+func node(prefix string, id int) string {
+	return quote(prefix + ".L" + strconv.Itoa(id))
+}
+
+func symNode(id int) string {
+	return quote("sym" + strconv.Itoa(id))
+}
+
+func blockName(id int, label string) string {
+	return ".L" + strconv.Itoa(id) + " " + label
+}
+
+func style(start bool) string {
+	if start {
+		return " style=bold"
+	}
+	return ""
+}
+
+// builtins and externs are dashed
+func callStyle(external, entry bool) string {
+	if external {
+		return " style=dashed"
+	}
+	if entry {
+		return " style=bold"
+	}
+	return ""
+}
+
+// record label of the form {name|instr\l...|flow\l}
+func record(name string, instrs []string, flow string) string {
+	output := "\"{" + escape(name) + "|"
+	for _, s := range instrs {
+		output += escape(s) + "\\l"
+	}
+	output += "|" + escape(flow) + "\\l}\""
+	return output
+}
+
+// escapes characters that are special inside record labels
+func escape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '{', '}', '|', '<', '>', '"', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func quote(s string) string {
+	return strconv.Quote(s)
+}
@@ -0,0 +1,159 @@
+package code
+
+// Code identifies the kind of a diagnostic, its values
+// are stable so that frontends can match on them.
+type Code int
+
+func (c Code) String() string {
+	switch c {
+	case Internal:
+		return "internal"
+	case Parse:
+		return "parse"
+	case Encoding:
+		return "encoding"
+	case Link:
+		return "link"
+	case Pipeline:
+		return "pipeline"
+
+	case MalformedInstr:
+		return "malformed-instr"
+	case UnequalTypes:
+		return "unequal-types"
+	case MalformedTypeOrClass:
+		return "malformed-type-or-class"
+	case InvalidInstrKind:
+		return "invalid-instr-kind"
+	case ExpectedProc:
+		return "expected-proc"
+	case CallNumOfArgs:
+		return "call-num-of-args"
+	case CallNumOfRets:
+		return "call-num-of-rets"
+	case CallBadArg:
+		return "call-bad-arg"
+	case CallBadRet:
+		return "call-bad-ret"
+	case InvalidFlow:
+		return "invalid-flow"
+	case ReturnNumOfValues:
+		return "return-num-of-values"
+	case ReturnBadType:
+		return "return-bad-type"
+	case InvalidExit:
+		return "invalid-exit"
+	case UnreachableBlock:
+		return "unreachable-block"
+	case InvalidSymbol:
+		return "invalid-symbol"
+	case DuplicatedSymbol:
+		return "duplicated-symbol"
+	case InvalidEntry:
+		return "invalid-entry"
+	case GlobalOutOfBounds:
+		return "global-out-of-bounds"
+	case GlobalBadType:
+		return "global-bad-type"
+	case InvalidBlock:
+		return "invalid-block"
+	case BlockOutOfBounds:
+		return "block-out-of-bounds"
+	case InvalidCondition:
+		return "invalid-condition"
+	case LitOutOfRange:
+		return "lit-out-of-range"
+	case UndefinedTemp:
+		return "undefined-temp"
+	case RedefinedTemp:
+		return "redefined-temp"
+	case UninitializedLocal:
+		return "uninitialized-local"
+	case IrreducibleFlow:
+		return "irreducible-flow"
+
+	case DivByZero:
+		return "div-by-zero"
+	case ShiftOverflow:
+		return "shift-overflow"
+	case SelfComparison:
+		return "self-comparison"
+	case UselessConvert:
+		return "useless-convert"
+
+	case InvalidClass:
+		return "invalid-class"
+	case NilInstr:
+		return "nil-instr"
+	case LoadingGarbage:
+		return "loading-garbage"
+	case LoadingIncorrectType:
+		return "loading-incorrect-type"
+	case RegisterGarbage:
+		return "register-garbage"
+	case IncorrectValueInRegister:
+		return "incorrect-value-in-register"
+	case ReturnStackEmpty:
+		return "return-stack-empty"
+	}
+	return "invalid Code"
+}
+
+// values are explicit and never reused, each section has room for
+// 100 codes, new codes are added at the end of their section
+const (
+	InvalidCode Code = 0
+
+	// general
+	Internal Code = 1
+	Parse    Code = 2
+	Encoding Code = 3
+	Link     Code = 4
+	Pipeline Code = 5
+
+	// PIR and MIR checkers
+	MalformedInstr       Code = 100
+	UnequalTypes         Code = 101
+	MalformedTypeOrClass Code = 102
+	InvalidInstrKind     Code = 103
+	ExpectedProc         Code = 104
+	CallNumOfArgs        Code = 105
+	CallNumOfRets        Code = 106
+	CallBadArg           Code = 107
+	CallBadRet           Code = 108
+	InvalidFlow          Code = 109
+	ReturnNumOfValues    Code = 110
+	ReturnBadType        Code = 111
+	InvalidExit          Code = 112
+	UnreachableBlock     Code = 113
+	InvalidSymbol        Code = 114
+	DuplicatedSymbol     Code = 115
+	InvalidEntry         Code = 116
+	GlobalOutOfBounds    Code = 117
+	GlobalBadType        Code = 118
+	InvalidBlock         Code = 119
+	BlockOutOfBounds     Code = 120
+	InvalidCondition     Code = 121
+	LitOutOfRange        Code = 122
+	UndefinedTemp        Code = 123
+	RedefinedTemp        Code = 124
+
+	// analyses
+	UninitializedLocal Code = 200
+	IrreducibleFlow    Code = 201
+
+	// lint rules
+	DivByZero      Code = 300
+	ShiftOverflow  Code = 301
+	SelfComparison Code = 302
+	UselessConvert Code = 303
+
+	// MIR checker only
+	InvalidClass             Code = 400
+	NilInstr                 Code = 401
+	LoadingGarbage           Code = 402
+	LoadingIncorrectType     Code = 403
+	RegisterGarbage          Code = 404
+	IncorrectValueInRegister Code = 405
+	ReturnStackEmpty         Code = 406
+)
@@ -1,3 +1,53 @@
 package errors
 
-type Error string
+import (
+	EC "github.com/padeir0/pir/errors/code"
+	sv "github.com/padeir0/pir/errors/severity"
+	"github.com/padeir0/pir/span"
+
+	"fmt"
+	"strconv"
+)
+
+// Error is a diagnostic, the location fields are filled
+// as far as they are known by whoever reports it.
+type Error struct {
+	Code     EC.Code
+	Severity sv.Severity
+
+	Proc  string // empty if unknown
+	Block int    // -1 if unknown
+	Index int    // index of the instruction in the block, -1 if unknown
+
+	// the offending instruction and operand (*pir.Instr, *mir.Operand, ...),
+	// nil if unknown
+	Instr   fmt.Stringer
+	Operand fmt.Stringer
+
+	Span    *span.Span // optional
+	Message string
+}
+
+// String formats the diagnostic as
+//
+//	[span: ][proc.Lblock#index: ][warning: ]message
+func (this *Error) String() string {
+	output := ""
+	if this.Span != nil {
+		output += this.Span.String() + ": "
+	}
+	location := this.Proc
+	if this.Block >= 0 {
+		location += ".L" + strconv.Itoa(this.Block)
+		if this.Index >= 0 {
+			location += "#" + strconv.Itoa(this.Index)
+		}
+	}
+	if location != "" {
+		output += location + ": "
+	}
+	if this.Severity == sv.Warning {
+		output += "warning: "
+	}
+	return output + this.Message
+}
@@ -0,0 +1,20 @@
+package severity
+
+type Severity int
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+	return "invalid Severity"
+}
+
+const (
+	InvalidSeverity Severity = iota
+
+	Error
+	Warning
+)
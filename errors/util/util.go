@@ -2,13 +2,66 @@ package util
 
 import (
 	. "github.com/padeir0/pir/errors"
+	EC "github.com/padeir0/pir/errors/code"
+	sv "github.com/padeir0/pir/errors/severity"
+	"github.com/padeir0/pir/span"
+
+	"fmt"
 )
 
 func NewInternalSemanticError(debug string) *Error {
-	return newInternalError(debug)
+	return newError(EC.Internal, debug)
+}
+
+func NewParseError(line, col int, message string) *Error {
+	err := newError(EC.Parse, message)
+	err.Span = &span.Span{Line: line, Col: col}
+	return err
+}
+
+func NewEncodingError(message string) *Error {
+	return newError(EC.Encoding, "encoding: "+message)
+}
+
+func NewLinkError(message string) *Error {
+	return newError(EC.Link, "link: "+message)
+}
+
+func NewPipelineError(message string) *Error {
+	return newError(EC.Pipeline, "pipeline: "+message)
+}
+
+// NewCheckError is used by the checkers, instr and op may be nil,
+// the location is filled by the caller.
+func NewCheckError(code EC.Code, instr, op fmt.Stringer, message string) *Error {
+	err := newError(code, message)
+	err.Instr = instr
+	err.Operand = op
+	return err
+}
+
+// NewCheckWarning is the same as NewCheckError, but for warnings.
+func NewCheckWarning(code EC.Code, instr, op fmt.Stringer, message string) *Error {
+	err := NewCheckError(code, instr, op, message)
+	err.Severity = sv.Warning
+	return err
+}
+
+// WithSpan sets the source position of err, if not yet known
+func WithSpan(sp *span.Span, err *Error) *Error {
+	if err == nil || err.Span != nil {
+		return err
+	}
+	err.Span = sp
+	return err
 }
 
-func newInternalError(message string) *Error {
-	e := Error(message)
-	return &e
+func newError(code EC.Code, message string) *Error {
+	return &Error{
+		Code:     code,
+		Severity: sv.Error,
+		Block:    -1,
+		Index:    -1,
+		Message:  message,
+	}
 }
@@ -56,6 +56,16 @@ func (i InstrKind) String() string {
 	panic("Unstringified InstrType: " + strconv.Itoa(int(i)))
 }
 
+/*
+Arithmetic wraps around at the width of the type. Div, Rem, ShiftRight,
+the ordered comparisons and widening Convert depend on the signedness
+of the operands: signed integers use signed division, arithmetic
+shifts, signed comparisons and sign extension, every other type uses
+unsigned division, logical shifts, unsigned comparisons and zero
+extension. ShiftRight prints as "sar" for both. Shift counts are
+masked like in amd64: to 6 bits for 64 bit types, 5 bits for the rest.
+Dividing by zero, or the minimum signed value by -1, traps.
+*/
 const (
 	InvalidInstr InstrKind = iota
 
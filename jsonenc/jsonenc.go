@@ -0,0 +1,487 @@
+package jsonenc
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/checker"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	FT "github.com/padeir0/pir/flowkind"
+	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/span"
+	T "github.com/padeir0/pir/types"
+
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Schema is the JSON Schema of the format used by Encode and Decode.
+//
+//go:embed schema.json
+var Schema string
+
+// Encode writes P to w as JSON. Instruction kinds, flow kinds, classes
+// and basic types are written as strings, procedure types are objects
+// with "args" and "rets". Every operand and every instruction but calls
+// must have a type, nil types are written as null and rejected by Decode.
+func Encode(w io.Writer, P *pir.Program) *Error {
+	out := program{
+		Name:    P.Name,
+		Entry:   int(P.Entry),
+		Symbols: make([]*symbol, len(P.Symbols)),
+	}
+	for i, sy := range P.Symbols {
+		out.Symbols[i] = fromSymbol(sy)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(out)
+	if err != nil {
+		return eu.NewEncodingError(err.Error())
+	}
+	return nil
+}
+
+// Decode reads a program written in the format of Encode,
+// unknown fields are rejected and the resulting program
+// must pass checker.CheckModule, the entry point may be invalid.
+func Decode(r io.Reader) (*pir.Program, *Error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var in program
+	err := dec.Decode(&in)
+	if err != nil {
+		return nil, eu.NewEncodingError(err.Error())
+	}
+	s := &state{types: T.NewInterner()}
+	P := &pir.Program{
+		Name:    in.Name,
+		Entry:   pir.SymbolID(in.Entry),
+		Symbols: make([]*pir.Symbol, len(in.Symbols)),
+	}
+	for i, sy := range in.Symbols {
+		var symErr *Error
+		P.Symbols[i], symErr = toSymbol(s, sy)
+		if symErr != nil {
+			return nil, symErr
+		}
+	}
+	checkErr := checker.CheckModule(P)
+	if checkErr != nil {
+		return nil, checkErr
+	}
+	return P, nil
+}
+
+type program struct {
+	Name    string    `json:"name"`
+	Entry   int       `json:"entry"`
+	Symbols []*symbol `json:"symbols"`
+}
+
+type symbol struct {
+	Builtin bool       `json:"builtin,omitempty"`
+	Extern  bool       `json:"extern,omitempty"`
+	Proc    *procedure `json:"proc,omitempty"`
+	Mem     *memory    `json:"mem,omitempty"`
+}
+
+type memory struct {
+	Label string `json:"label"`
+	Data  string `json:"data,omitempty"`
+	Size  uint64 `json:"size,omitempty"`
+}
+
+type procedure struct {
+	Label  string      `json:"label"`
+	Args   []*jsonType `json:"args"`
+	Rets   []*jsonType `json:"rets"`
+	Vars   []*jsonType `json:"vars"`
+	Start  int         `json:"start"`
+	Blocks []*block    `json:"blocks"`
+	Span   *srcSpan    `json:"span,omitempty"`
+}
+
+type block struct {
+	Label string   `json:"label"`
+	Code  []*instr `json:"code"`
+	Out   flow     `json:"out"`
+}
+
+type instr struct {
+	Kind        string     `json:"kind"`
+	Type        *jsonType  `json:"type,omitempty"`
+	Operands    []*operand `json:"operands"`
+	Destination []*operand `json:"destination"`
+	Span        *srcSpan   `json:"span,omitempty"`
+}
+
+type flow struct {
+	Kind   string     `json:"kind"`
+	Values []*operand `json:"values"`
+	True   int        `json:"true"`
+	False  int        `json:"false"`
+	Span   *srcSpan   `json:"span,omitempty"`
+}
+
+type srcSpan struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+}
+
+type operand struct {
+	Class string    `json:"class"`
+	Type  *jsonType `json:"type"`
+	Num   uint64    `json:"num"`
+}
+
+// basic and special types are strings,
+// procedure types are {"args": [...], "rets": [...]}
+type jsonType struct {
+	Name string
+	Args []*jsonType
+	Rets []*jsonType
+}
+
+type jsonProcType struct {
+	Args []*jsonType `json:"args"`
+	Rets []*jsonType `json:"rets"`
+}
+
+func (t *jsonType) MarshalJSON() ([]byte, error) {
+	if t.Name != "" {
+		return json.Marshal(t.Name)
+	}
+	return json.Marshal(jsonProcType{Args: t.Args, Rets: t.Rets})
+}
+
+func (t *jsonType) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		err := json.Unmarshal(data, &t.Name)
+		if err != nil {
+			return err
+		}
+		// an empty name would be taken for a procedure type
+		if _, ok := T.Named(t.Name); !ok {
+			return fmt.Errorf("invalid type: %q", t.Name)
+		}
+		return nil
+	}
+	var p jsonProcType
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+	t.Args = p.Args
+	t.Rets = p.Rets
+	if t.Args == nil {
+		t.Args = []*jsonType{}
+	}
+	if t.Rets == nil {
+		t.Rets = []*jsonType{}
+	}
+	return nil
+}
+
+func fromSymbol(sy *pir.Symbol) *symbol {
+	if sy == nil {
+		return nil
+	}
+	out := &symbol{Builtin: sy.Builtin, Extern: sy.Extern}
+	if sy.Proc != nil {
+		out.Proc = fromProc(sy.Proc)
+	}
+	if sy.Mem != nil {
+		out.Mem = &memory{Label: sy.Mem.Label, Data: sy.Mem.Data, Size: sy.Mem.Size}
+	}
+	return out
+}
+
+func fromProc(proc *pir.Procedure) *procedure {
+	out := &procedure{
+		Label:  proc.Label,
+		Args:   fromTypes(proc.Args),
+		Rets:   fromTypes(proc.Rets),
+		Vars:   fromTypes(proc.Vars),
+		Start:  int(proc.Start),
+		Blocks: make([]*block, len(proc.AllBlocks)),
+		Span:   fromSpan(proc.Span),
+	}
+	for i, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		b := &block{
+			Label: bb.Label,
+			Code:  make([]*instr, len(bb.Code)),
+			Out: flow{
+				Kind:   bb.Out.T.String(),
+				Values: fromOperands(bb.Out.V),
+				True:   int(bb.Out.True),
+				False:  int(bb.Out.False),
+				Span:   fromSpan(bb.Out.Span),
+			},
+		}
+		for j, in := range bb.Code {
+			b.Code[j] = &instr{
+				Kind:        instrName(in.T),
+				Type:        fromType(in.Type),
+				Operands:    fromOperands(in.Operands),
+				Destination: fromOperands(in.Destination),
+				Span:        fromSpan(in.Span),
+			}
+		}
+		out.Blocks[i] = b
+	}
+	return out
+}
+
+func instrName(k IT.InstrKind) string {
+	if k < IT.Add || k > IT.Call {
+		return "invalid"
+	}
+	return k.String()
+}
+
+func fromOperands(ops []pir.Operand) []*operand {
+	if ops == nil {
+		return nil
+	}
+	out := make([]*operand, len(ops))
+	for i, op := range ops {
+		out[i] = &operand{
+			Class: op.Class.String(),
+			Type:  fromType(op.Type),
+			Num:   op.Num,
+		}
+	}
+	return out
+}
+
+func fromSpan(sp *span.Span) *srcSpan {
+	if sp == nil {
+		return nil
+	}
+	return &srcSpan{File: sp.File, Line: sp.Line, Col: sp.Col}
+}
+
+func fromTypes(tps []*T.Type) []*jsonType {
+	out := make([]*jsonType, len(tps))
+	for i, t := range tps {
+		out[i] = fromType(t)
+	}
+	return out
+}
+
+func fromType(t *T.Type) *jsonType {
+	if t == nil {
+		return nil
+	}
+	if T.IsBasic(t) || t.Special != T.InvalidSpecialType || !T.IsProc(t) {
+		return &jsonType{Name: t.String()}
+	}
+	return &jsonType{
+		Args: fromTypes(t.Proc.Args),
+		Rets: fromTypes(t.Proc.Rets),
+	}
+}
+
+type state struct {
+	types *T.Interner
+}
+
+func toSymbol(s *state, sy *symbol) (*pir.Symbol, *Error) {
+	if sy == nil {
+		return nil, eu.NewEncodingError("null symbol")
+	}
+	out := &pir.Symbol{Builtin: sy.Builtin, Extern: sy.Extern}
+	if sy.Proc != nil {
+		proc, err := toProc(s, sy.Proc)
+		if err != nil {
+			return nil, err
+		}
+		out.Proc = proc
+	}
+	if sy.Mem != nil {
+		out.Mem = &pir.MemoryDecl{Label: sy.Mem.Label, Data: sy.Mem.Data, Size: sy.Mem.Size}
+	}
+	if out.Proc == nil && out.Mem == nil {
+		return nil, eu.NewEncodingError("symbol is neither a procedure nor memory")
+	}
+	return out, nil
+}
+
+func toProc(s *state, proc *procedure) (*pir.Procedure, *Error) {
+	out := &pir.Procedure{
+		Label:     proc.Label,
+		Start:     pir.BlockID(proc.Start),
+		AllBlocks: make([]*pir.BasicBlock, len(proc.Blocks)),
+		Span:      toSpan(proc.Span),
+	}
+	var err *Error
+	out.Args, err = toTypes(s, proc.Args)
+	if err != nil {
+		return nil, err
+	}
+	out.Rets, err = toTypes(s, proc.Rets)
+	if err != nil {
+		return nil, err
+	}
+	out.Vars, err = toTypes(s, proc.Vars)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range proc.Blocks {
+		if b == nil {
+			return nil, eu.NewEncodingError(proc.Label + ": null block")
+		}
+		bb := &pir.BasicBlock{
+			Label: b.Label,
+			Code:  make([]pir.Instr, len(b.Code)),
+		}
+		for j, in := range b.Code {
+			if in == nil {
+				return nil, eu.NewEncodingError(b.Label + ": null instruction")
+			}
+			bb.Code[j], err = toInstr(s, in)
+			if err != nil {
+				return nil, err
+			}
+		}
+		kind, ok := flowKinds[b.Out.Kind]
+		if !ok {
+			return nil, eu.NewEncodingError(b.Label + ": invalid flow kind: " + b.Out.Kind)
+		}
+		values, err := toOperands(s, b.Out.Values)
+		if err != nil {
+			return nil, err
+		}
+		bb.Out = pir.Flow{
+			T:     kind,
+			V:     values,
+			True:  pir.BlockID(b.Out.True),
+			False: pir.BlockID(b.Out.False),
+			Span:  toSpan(b.Out.Span),
+		}
+		out.AllBlocks[i] = bb
+	}
+	return out, nil
+}
+
+func toInstr(s *state, in *instr) (pir.Instr, *Error) {
+	kind, ok := instrKinds[in.Kind]
+	if !ok {
+		return pir.Instr{}, eu.NewEncodingError("invalid instruction kind: " + in.Kind)
+	}
+	// calls take their type from the procedure operand
+	var t *T.Type
+	if kind == IT.Call {
+		if in.Type != nil {
+			return pir.Instr{}, eu.NewEncodingError("call: unexpected type")
+		}
+	} else {
+		if in.Type == nil {
+			return pir.Instr{}, eu.NewEncodingError(in.Kind + ": missing or null type")
+		}
+		var err *Error
+		t, err = toType(s, in.Type)
+		if err != nil {
+			return pir.Instr{}, err
+		}
+	}
+	ops, err := toOperands(s, in.Operands)
+	if err != nil {
+		return pir.Instr{}, err
+	}
+	dests, err := toOperands(s, in.Destination)
+	if err != nil {
+		return pir.Instr{}, err
+	}
+	return pir.Instr{T: kind, Type: t, Operands: ops, Destination: dests, Span: toSpan(in.Span)}, nil
+}
+
+func toSpan(sp *srcSpan) *span.Span {
+	if sp == nil {
+		return nil
+	}
+	return &span.Span{File: sp.File, Line: sp.Line, Col: sp.Col}
+}
+
+func toOperands(s *state, ops []*operand) ([]pir.Operand, *Error) {
+	if ops == nil {
+		return nil, nil
+	}
+	out := make([]pir.Operand, len(ops))
+	for i, op := range ops {
+		if op == nil {
+			return nil, eu.NewEncodingError("null operand")
+		}
+		class, ok := classes[op.Class]
+		if !ok {
+			return nil, eu.NewEncodingError("invalid operand class: " + op.Class)
+		}
+		if op.Type == nil {
+			return nil, eu.NewEncodingError(op.Class + " operand: missing or null type")
+		}
+		t, err := toType(s, op.Type)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = pir.Operand{Class: class, Type: t, Num: op.Num}
+	}
+	return out, nil
+}
+
+func toTypes(s *state, tps []*jsonType) ([]*T.Type, *Error) {
+	out := make([]*T.Type, len(tps))
+	for i, t := range tps {
+		var err *Error
+		out[i], err = toType(s, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// equal types are deduplicated, every type is required
+func toType(s *state, t *jsonType) (*T.Type, *Error) {
+	if t == nil {
+		return nil, eu.NewEncodingError("missing or null type")
+	}
+	if t.Name != "" {
+		named, ok := T.Named(t.Name)
+		if !ok {
+			return nil, eu.NewEncodingError("invalid type: " + t.Name)
+		}
+		return named, nil
+	}
+	args, err := toTypes(s, t.Args)
+	if err != nil {
+		return nil, err
+	}
+	rets, err := toTypes(s, t.Rets)
+	if err != nil {
+		return nil, err
+	}
+	return s.types.Proc(args, rets), nil
+}
+
+var instrKinds = map[string]IT.InstrKind{}
+var flowKinds = map[string]FT.FlowKind{}
+var classes = map[string]hirc.Class{}
+
+func init() {
+	for k := IT.Add; k <= IT.Call; k++ {
+		instrKinds[k.String()] = k
+	}
+	for k := FT.Jmp; k <= FT.Exit; k++ {
+		flowKinds[k.String()] = k
+	}
+	for c := hirc.Temp; c <= hirc.Global; c++ {
+		classes[c.String()] = c
+	}
+}
@@ -0,0 +1,188 @@
+package jsonenc
+
+import (
+	"github.com/padeir0/pir"
+	EC "github.com/padeir0/pir/errors/code"
+	"github.com/padeir0/pir/parse"
+	T "github.com/padeir0/pir/types"
+
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const sample = `Program: sample
+
+write: builtin
+msg: "hello\n"
+buff: 64
+ext: extern proc[i64][i64]
+data: extern mem
+square{
+i64, i32
+i64
+
+}:
+square_b0:
+	mult:i64 arg#0:i64, arg#0:i64 -> '0:i64
+	ret '0:i64
+
+
+main{
+
+
+i64, bool, i8
+}:
+main_b0:
+	call, global#5:proc[i64, i32][i64], 5, 2 -> local#0:i64
+	less:i64 local#0:i64, 30 -> '1:bool
+	copy:i8 -1 -> local#2:i8
+	if '1:bool? .L1 : .L2
+main_b1:
+	storeptr:i64 7, global#2:ptr
+	jmp .L2
+main_b2:
+	exit local#2:i8
+`
+
+func TestRoundTrip(t *testing.T) {
+	P, err := parse.Program(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buff bytes.Buffer
+	err = Encode(&buff, P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Q, err := Decode(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if P.String() != Q.String() {
+		t.Errorf("got:\n%v\nexpected:\n%v", Q.String(), P.String())
+	}
+	if P.Entry != Q.Entry {
+		t.Errorf("entry is %v, expected %v", Q.Entry, P.Entry)
+	}
+	square := Q.Symbols[5].Proc
+	if square.Args[0] != T.T_I64 || square.Args[1] != T.T_I32 {
+		t.Errorf("basic types are not the ones in the types package")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var schema map[string]interface{}
+	err := json.Unmarshal([]byte(Schema), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a program with a single procedure, main, with a variable of type i8
+func single(args, code string) string {
+	return `{"name":"x","entry":0,"symbols":[{"proc":{"label":"main","args":[` + args +
+		`],"rets":[],"vars":["i8"],"blocks":[{"label":"b0","code":[` + code +
+		`],"out":{"kind":"exit","values":[{"class":"lit","type":"i8","num":0}]}}]}}]}`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		code    EC.Code // InvalidCode if valid
+		message string
+	}{
+		{
+			"valid",
+			single(`"i64"`, `{"kind":"copy","type":"i8","operands":[{"class":"lit","type":"i8","num":0}],"destination":[{"class":"local","type":"i8","num":0}]}`),
+			EC.InvalidCode,
+			"",
+		},
+		{"empty type", single(`""`, ``), EC.Encoding, `invalid type: ""`},
+		{"unknown type", single(`"i65"`, ``), EC.Encoding, `invalid type: "i65"`},
+		{"null arg", single(`null`, ``), EC.Encoding, "missing or null type"},
+		{
+			"null instr type",
+			single(``, `{"kind":"copy","type":null,"operands":[{"class":"lit","type":"i8","num":0}],"destination":[{"class":"local","type":"i8","num":0}]}`),
+			EC.Encoding,
+			"copy: missing or null type",
+		},
+		{
+			"missing instr type",
+			single(``, `{"kind":"copy","operands":[{"class":"lit","type":"i8","num":0}],"destination":[{"class":"local","type":"i8","num":0}]}`),
+			EC.Encoding,
+			"copy: missing or null type",
+		},
+		{
+			"null operand type",
+			single(``, `{"kind":"copy","type":"i8","operands":[{"class":"lit","type":null,"num":0}],"destination":[{"class":"local","type":"i8","num":0}]}`),
+			EC.Encoding,
+			"lit operand: missing or null type",
+		},
+		{
+			"typed call",
+			single(``, `{"kind":"call","type":"i8","operands":[{"class":"global","type":{"args":[],"rets":[]},"num":0}]}`),
+			EC.Encoding,
+			"call: unexpected type",
+		},
+		{
+			"unknown kind",
+			single(``, `{"kind":"mul","type":"i8","operands":[],"destination":[]}`),
+			EC.Encoding,
+			"invalid instruction kind: mul",
+		},
+		{
+			"unknown field",
+			single(``, `{"kind":"copy","foo":1,"type":"i8","operands":[{"class":"lit","type":"i8","num":0}],"destination":[{"class":"local","type":"i8","num":0}]}`),
+			EC.Encoding,
+			`unknown field "foo"`,
+		},
+		{
+			"unequal types",
+			single(``, `{"kind":"copy","type":"i8","operands":[{"class":"lit","type":"i32","num":0}],"destination":[{"class":"local","type":"i8","num":0}]}`),
+			EC.UnequalTypes,
+			"unequal types",
+		},
+		{"not json", `{"name":`, EC.Encoding, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(strings.NewReader(tt.input))
+		if tt.code == EC.InvalidCode {
+			if err != nil {
+				t.Errorf("%v: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+			continue
+		}
+		if err.Code != tt.code {
+			t.Errorf("%v: error code is %v, expected %v", tt.name, err.Code, tt.code)
+		}
+		if !strings.Contains(err.Message, tt.message) {
+			t.Errorf("%v: error %q doesn't mention %q", tt.name, err.Message, tt.message)
+		}
+	}
+}
+
+func TestNoEntry(t *testing.T) {
+	P, err := parse.Program("Program: lib\n\nbuff: 8\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buff bytes.Buffer
+	err = Encode(&buff, P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Q, err := Decode(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Q.Entry != pir.NoEntry {
+		t.Errorf("entry is %v, expected %v", Q.Entry, pir.NoEntry)
+	}
+}
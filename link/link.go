@@ -0,0 +1,320 @@
+package link
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	T "github.com/padeir0/pir/types"
+
+	"strconv"
+)
+
+// Link merges separately compiled modules into a single program.
+//
+// Symbols are matched by label: every extern declaration is resolved
+// to the definition with the same label, which must be of the same kind,
+// and, for procedures, of the same type. Builtins with the same label
+// are merged, any other label defined twice is an error.
+//
+// Definitions keep their relative order, Global operands are renumbered
+// to match, and equal types become the same object. Exactly one module
+// must set its Entry to a procedure it defines, that procedure becomes
+// the entry point and the name of that module is the name of the program,
+// the other modules should use pir.NoEntry. The modules are not modified.
+func Link(modules ...*pir.Program) (*pir.Program, *Error) {
+	if len(modules) == 0 {
+		return nil, eu.NewLinkError("no modules to link")
+	}
+	l := newLinker(modules)
+	err := collectDefinitions(l)
+	if err != nil {
+		return nil, err
+	}
+	err = resolveExterns(l)
+	if err != nil {
+		return nil, err
+	}
+	err = copyProcedures(l)
+	if err != nil {
+		return nil, err
+	}
+	err = setEntry(l)
+	if err != nil {
+		return nil, err
+	}
+	return l.output, nil
+}
+
+// a module sets the entry point when its Entry is a procedure it
+// defines, see pir.NoEntry
+func setEntry(l *linker) *Error {
+	found := -1
+	for m, module := range l.modules {
+		if !hasEntry(module) {
+			continue
+		}
+		if found >= 0 {
+			return severalEntries(l, found, m)
+		}
+		found = m
+	}
+	if found < 0 {
+		return noEntry()
+	}
+	module := l.modules[found]
+	id := l.remap[found][module.Entry]
+	sy := l.output.Symbols[id]
+	if sy.Proc == nil || sy.Builtin || sy.Extern {
+		return invalidEntry(l, found)
+	}
+	l.output.Name = module.Name
+	l.output.Entry = id
+	return nil
+}
+
+func hasEntry(module *pir.Program) bool {
+	if module.Entry < 0 || int(module.Entry) >= len(module.Symbols) {
+		return false
+	}
+	sy := module.Symbols[module.Entry]
+	return sy.Proc != nil && !sy.Builtin && !sy.Extern
+}
+
+type definition struct {
+	id     pir.SymbolID
+	module int
+	symbol *pir.Symbol
+}
+
+type linker struct {
+	modules []*pir.Program
+	output  *pir.Program
+
+	// remap[module][old symbol] returns the symbol in the output
+	remap       [][]pir.SymbolID
+	definitions map[string]*definition
+	// in the same order as the output symbols
+	ordered []*definition
+	// equal types of different modules are the same object in the output
+	types *T.Interner
+}
+
+func newLinker(modules []*pir.Program) *linker {
+	return &linker{
+		modules:     modules,
+		output:      pir.NewProgram(),
+		remap:       make([][]pir.SymbolID, len(modules)),
+		definitions: map[string]*definition{},
+		types:       T.NewInterner(),
+	}
+}
+
+func collectDefinitions(l *linker) *Error {
+	for m, module := range l.modules {
+		if module == nil {
+			return eu.NewLinkError("module " + strconv.Itoa(m) + " is nil")
+		}
+		l.remap[m] = make([]pir.SymbolID, len(module.Symbols))
+		for i, sy := range module.Symbols {
+			if sy == nil || (sy.Proc == nil && sy.Mem == nil) {
+				return invalidSymbol(l, m, i)
+			}
+			if sy.Extern {
+				continue
+			}
+			label := symbolLabel(sy)
+			old, ok := l.definitions[label]
+			if ok {
+				if sy.Builtin && old.symbol.Builtin {
+					l.remap[m][i] = old.id
+					continue
+				}
+				return duplicated(l, label, old.module, m)
+			}
+			id := pir.SymbolID(len(l.output.Symbols))
+			l.output.Symbols = append(l.output.Symbols, copySymbol(sy))
+			def := &definition{id: id, module: m, symbol: sy}
+			l.definitions[label] = def
+			l.ordered = append(l.ordered, def)
+			l.remap[m][i] = id
+		}
+	}
+	return nil
+}
+
+func resolveExterns(l *linker) *Error {
+	for m, module := range l.modules {
+		for i, sy := range module.Symbols {
+			if !sy.Extern {
+				continue
+			}
+			label := symbolLabel(sy)
+			def, ok := l.definitions[label]
+			if !ok {
+				return undefined(l, label, m)
+			}
+			if sy.Proc != nil {
+				if def.symbol.Proc == nil {
+					return kindMismatch(l, label, m, sy, def)
+				}
+				if !def.symbol.Builtin && !sameSignature(sy.Proc, def.symbol.Proc) {
+					return typeMismatch(l, label, m, sy.Proc, def.module, def.symbol.Proc)
+				}
+			} else if def.symbol.Mem == nil {
+				return kindMismatch(l, label, m, sy, def)
+			}
+			l.remap[m][i] = def.id
+		}
+	}
+	return nil
+}
+
+func copyProcedures(l *linker) *Error {
+	for _, def := range l.ordered {
+		out := l.output.Symbols[def.id]
+		if out.Proc == nil {
+			continue
+		}
+		err := copyProc(l, def.module, def.symbol.Proc, out.Proc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// out already has the label, only the rest is filled
+func copyProc(l *linker, m int, proc *pir.Procedure, out *pir.Procedure) *Error {
+	out.Vars = l.types.InternAll(proc.Vars)
+	out.Args = l.types.InternAll(proc.Args)
+	out.Rets = l.types.InternAll(proc.Rets)
+	out.Start = proc.Start
+	out.Span = proc.Span
+	if proc.AllBlocks == nil {
+		return nil
+	}
+	out.AllBlocks = make([]*pir.BasicBlock, len(proc.AllBlocks))
+	for i, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		newBB := &pir.BasicBlock{
+			Label: bb.Label,
+			Code:  make([]pir.Instr, len(bb.Code)),
+			Out:   bb.Out,
+		}
+		for j, instr := range bb.Code {
+			ops, err := copyOperands(l, m, proc, instr.Operands)
+			if err != nil {
+				return err
+			}
+			dests, err := copyOperands(l, m, proc, instr.Destination)
+			if err != nil {
+				return err
+			}
+			instr.Type = l.types.Intern(instr.Type)
+			instr.Operands = ops
+			instr.Destination = dests
+			newBB.Code[j] = instr
+		}
+		values, err := copyOperands(l, m, proc, bb.Out.V)
+		if err != nil {
+			return err
+		}
+		newBB.Out.V = values
+		out.AllBlocks[i] = newBB
+	}
+	return nil
+}
+
+func copyOperands(l *linker, m int, proc *pir.Procedure, ops []pir.Operand) ([]pir.Operand, *Error) {
+	if ops == nil {
+		return nil, nil
+	}
+	output := make([]pir.Operand, len(ops))
+	for i, op := range ops {
+		op.Type = l.types.Intern(op.Type)
+		if op.Class == hirc.Global {
+			if op.Num >= uint64(len(l.remap[m])) {
+				return nil, globalOutOfBounds(l, m, proc, op)
+			}
+			op.Num = uint64(l.remap[m][op.Num])
+		}
+		output[i] = op
+	}
+	return output, nil
+}
+
+// procedures are filled later by copyProc
+func copySymbol(sy *pir.Symbol) *pir.Symbol {
+	out := &pir.Symbol{Builtin: sy.Builtin}
+	if sy.Proc != nil {
+		out.Proc = &pir.Procedure{Label: sy.Proc.Label}
+	}
+	if sy.Mem != nil {
+		mem := *sy.Mem
+		out.Mem = &mem
+	}
+	return out
+}
+
+func sameSignature(a, b *pir.Procedure) bool {
+	ta := &T.ProcType{Args: a.Args, Rets: a.Rets}
+	tb := &T.ProcType{Args: b.Args, Rets: b.Rets}
+	return ta.Equals(tb)
+}
+
+func symbolLabel(sy *pir.Symbol) string {
+	if sy.Proc != nil {
+		return sy.Proc.Label
+	}
+	return sy.Mem.Label
+}
+
+func symbolKind(sy *pir.Symbol) string {
+	if sy.Proc != nil {
+		return "procedure"
+	}
+	return "memory"
+}
+
+func moduleName(l *linker, m int) string {
+	name := l.modules[m].Name
+	if name == "" {
+		return "module " + strconv.Itoa(m)
+	}
+	return "module " + name
+}
+
+func invalidSymbol(l *linker, m int, i int) *Error {
+	return eu.NewLinkError(moduleName(l, m) + ": invalid symbol " + strconv.Itoa(i))
+}
+func duplicated(l *linker, label string, first, second int) *Error {
+	return eu.NewLinkError(label + " is defined in both " + moduleName(l, first) + " and " + moduleName(l, second))
+}
+func undefined(l *linker, label string, m int) *Error {
+	return eu.NewLinkError(label + " is declared in " + moduleName(l, m) + " but never defined")
+}
+func kindMismatch(l *linker, label string, m int, decl *pir.Symbol, def *definition) *Error {
+	return eu.NewLinkError(label + " is declared as " + symbolKind(decl) + " in " + moduleName(l, m) +
+		" but defined as " + symbolKind(def.symbol) + " in " + moduleName(l, def.module))
+}
+func typeMismatch(l *linker, label string, m int, decl *pir.Procedure, def int, found *pir.Procedure) *Error {
+	declT := &T.ProcType{Args: decl.Args, Rets: decl.Rets}
+	foundT := &T.ProcType{Args: found.Args, Rets: found.Rets}
+	return eu.NewLinkError(label + " is declared as " + declT.String() + " in " + moduleName(l, m) +
+		" but defined as " + foundT.String() + " in " + moduleName(l, def))
+}
+func noEntry() *Error {
+	return eu.NewLinkError("no module sets an entry point")
+}
+func severalEntries(l *linker, first, second int) *Error {
+	return eu.NewLinkError("entry point is set by both " + moduleName(l, first) + " and " + moduleName(l, second))
+}
+func invalidEntry(l *linker, m int) *Error {
+	return eu.NewLinkError("the entry point of " + moduleName(l, m) + " is not a procedure")
+}
+func globalOutOfBounds(l *linker, m int, proc *pir.Procedure, op pir.Operand) *Error {
+	return eu.NewLinkError(moduleName(l, m) + ": " + proc.Label + ": global out of bounds: " + op.String())
+}
@@ -0,0 +1,217 @@
+package link
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/checker"
+	EC "github.com/padeir0/pir/errors/code"
+	"github.com/padeir0/pir/parse"
+	T "github.com/padeir0/pir/types"
+
+	"strings"
+	"testing"
+)
+
+// uses square and buff, defined in b
+const a = `Program: a
+
+write: builtin
+square: extern proc[i64, i32][i64]
+buff: extern mem
+main{
+
+
+i64
+}:
+main_b0:
+	call, global#1:proc[i64, i32][i64], 5, 2 -> local#0:i64
+	storeptr:i64 local#0:i64, global#2:ptr
+	exit 0
+`
+
+const b = `Program: b
+
+write: builtin
+msg: "hello\n"
+buff: 64
+square{
+i64, i32
+i64
+
+}:
+square_b0:
+	mult:i64 arg#0:i64, arg#0:i64 -> '0:i64
+	ret '0:i64
+`
+
+func modules(t *testing.T) (*pir.Program, *pir.Program) {
+	A, err := parse.Program(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	B, err := parse.Program(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return A, B
+}
+
+func TestLink(t *testing.T) {
+	A, B := modules(t)
+	L, err := Link(A, B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checker.Check(L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if L.Name != "a" {
+		t.Errorf("name is %v, expected a", L.Name)
+	}
+	labels := []string{}
+	for _, sy := range L.Symbols {
+		if sy.Extern {
+			t.Errorf("%v is still extern", sy)
+		}
+		if sy.Proc != nil {
+			labels = append(labels, sy.Proc.Label)
+		} else {
+			labels = append(labels, sy.Mem.Label)
+		}
+	}
+	expected := "write main msg buff square"
+	if strings.Join(labels, " ") != expected {
+		t.Errorf("symbols are %v, expected %v", labels, expected)
+	}
+	if L.Symbols[L.Entry].Proc.Label != "main" {
+		t.Errorf("entry is %v, expected main", L.Symbols[L.Entry])
+	}
+	// the call in main and square itself must agree on the type
+	call := L.Symbols[L.Entry].Proc.AllBlocks[0].Code[0].Operands[0]
+	square := L.Symbols[call.Num].Proc
+	if square.Label != "square" {
+		t.Errorf("call resolved to %v, expected square", square.Label)
+	}
+	if square.Args[0] != T.T_I64 || call.Type.Proc.Args[0] != T.T_I64 {
+		t.Errorf("basic types are not the ones in the types package")
+	}
+	// the modules are not modified
+	if !A.Symbols[1].Extern || len(A.Symbols) != 4 {
+		t.Errorf("module a was modified")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules func(A, B *pir.Program) []*pir.Program
+		message string // empty if valid
+	}{
+		{
+			"no modules",
+			func(A, B *pir.Program) []*pir.Program { return nil },
+			"no modules to link",
+		},
+		{
+			"nil module",
+			func(A, B *pir.Program) []*pir.Program { return []*pir.Program{A, nil} },
+			"is nil",
+		},
+		{
+			"undefined",
+			func(A, B *pir.Program) []*pir.Program { return []*pir.Program{A} },
+			"square is declared in module a but never defined",
+		},
+		{
+			"duplicated",
+			func(A, B *pir.Program) []*pir.Program { return []*pir.Program{A, B, B} },
+			"msg is defined in both module b and module b",
+		},
+		{
+			"type mismatch",
+			func(A, B *pir.Program) []*pir.Program {
+				A.Symbols[1].Proc.Args = []*T.Type{T.T_I64}
+				return []*pir.Program{A, B}
+			},
+			"square is declared as proc[i64][i64] in module a but defined as proc[i64, i32][i64] in module b",
+		},
+		{
+			"kind mismatch",
+			func(A, B *pir.Program) []*pir.Program {
+				A.Symbols[1] = &pir.Symbol{Mem: &pir.MemoryDecl{Label: "square"}, Extern: true}
+				return []*pir.Program{A, B}
+			},
+			"square is declared as memory in module a but defined as procedure in module b",
+		},
+		{
+			"global out of bounds",
+			func(A, B *pir.Program) []*pir.Program {
+				A.Symbols[3].Proc.AllBlocks[0].Code[0].Operands[0].Num = 9
+				return []*pir.Program{A, B}
+			},
+			"main: global out of bounds",
+		},
+		{
+			"several entries",
+			func(A, B *pir.Program) []*pir.Program {
+				B.Entry = 3
+				return []*pir.Program{A, B}
+			},
+			"entry point is set by both module a and module b",
+		},
+		{
+			"no entry",
+			func(A, B *pir.Program) []*pir.Program {
+				A.Entry = pir.NoEntry
+				return []*pir.Program{A, B}
+			},
+			"no module sets an entry point",
+		},
+		{
+			"extern entry",
+			func(A, B *pir.Program) []*pir.Program {
+				A.Entry = 1
+				return []*pir.Program{A, B}
+			},
+			"no module sets an entry point",
+		},
+		{
+			"builtin entry is ignored",
+			func(A, B *pir.Program) []*pir.Program {
+				B.Entry = 0
+				return []*pir.Program{A, B}
+			},
+			"",
+		},
+		{
+			"entry out of bounds is ignored",
+			func(A, B *pir.Program) []*pir.Program {
+				B.Entry = 99
+				return []*pir.Program{A, B}
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		A, B := modules(t)
+		L, err := Link(tt.modules(A, B)...)
+		if tt.message == "" {
+			if err != nil {
+				t.Errorf("%v: %v", tt.name, err)
+			} else if L.Name != "a" {
+				t.Errorf("%v: name is %v, expected a", tt.name, L.Name)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+			continue
+		}
+		if err.Code != EC.Link {
+			t.Errorf("%v: error code is %v, expected %v", tt.name, err.Code, EC.Link)
+		}
+		if !strings.Contains(err.Message, tt.message) {
+			t.Errorf("%v: error %q doesn't mention %q", tt.name, err.Message, tt.message)
+		}
+	}
+}
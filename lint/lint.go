@@ -0,0 +1,149 @@
+package lint
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	EC "github.com/padeir0/pir/errors/code"
+	eu "github.com/padeir0/pir/errors/util"
+	IT "github.com/padeir0/pir/instrkind"
+	T "github.com/padeir0/pir/types"
+
+	"strconv"
+)
+
+/*
+Warns about IR that is legal, but almost certainly wrong.
+
+Every diagnostic is a warning with the code of the rule that found it,
+linting never fails on its own. Malformed instructions are skipped,
+those are reported by checker.Check.
+*/
+
+// Rules selects which rules are run, see All.
+type Rules struct {
+	// div or rem by the literal 0
+	DivByZero bool
+	// shift by a literal at or above the bit width of the type
+	ShiftOverflow bool
+	// comparison of a temp, local, argument or global with itself,
+	// comparisons of literals are left to constant folding
+	SelfComparison bool
+	// convert to the type the operand already has
+	UselessConvert bool
+}
+
+var All = Rules{
+	DivByZero:      true,
+	ShiftOverflow:  true,
+	SelfComparison: true,
+	UselessConvert: true,
+}
+
+// Lint runs the selected rules over every procedure of P.
+func Lint(P *pir.Program, rules Rules) []*Error {
+	diags := []*Error{}
+	for _, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil || sy.Builtin || sy.Extern {
+			continue
+		}
+		diags = append(diags, Procedure(sy.Proc, rules)...)
+	}
+	return diags
+}
+
+// Procedure is the same as Lint, but for a single procedure.
+func Procedure(proc *pir.Procedure, rules Rules) []*Error {
+	diags := []*Error{}
+	for id, bb := range proc.AllBlocks {
+		if bb == nil {
+			continue
+		}
+		for i := range bb.Code {
+			instr := &bb.Code[i]
+			err := lintInstr(instr, rules)
+			if err == nil {
+				continue
+			}
+			err.Proc = proc.Label
+			err.Block = id
+			err.Index = i
+			sp := instr.Span
+			if sp == nil {
+				sp = proc.Span
+			}
+			diags = append(diags, eu.WithSpan(sp, err))
+		}
+	}
+	return diags
+}
+
+// at most one warning per instruction
+func lintInstr(instr *pir.Instr, rules Rules) *Error {
+	switch instr.T {
+	case IT.Div, IT.Rem:
+		if rules.DivByZero {
+			return divByZero(instr)
+		}
+	case IT.ShiftLeft, IT.ShiftRight:
+		if rules.ShiftOverflow {
+			return shiftOverflow(instr)
+		}
+	case IT.Eq, IT.Diff, IT.Less, IT.More, IT.LessEq, IT.MoreEq:
+		if rules.SelfComparison {
+			return selfComparison(instr)
+		}
+	case IT.Convert:
+		if rules.UselessConvert {
+			return uselessConvert(instr)
+		}
+	}
+	return nil
+}
+
+func divByZero(instr *pir.Instr) *Error {
+	if len(instr.Operands) != 2 {
+		return nil
+	}
+	b := instr.Operands[1]
+	if b.Class == hirc.Lit && b.Num == 0 {
+		return eu.NewCheckWarning(EC.DivByZero, instr, &b, instr.T.String()+" by zero")
+	}
+	return nil
+}
+
+func shiftOverflow(instr *pir.Instr) *Error {
+	if len(instr.Operands) != 2 || instr.Type == nil || !T.IsNumber(instr.Type) {
+		return nil
+	}
+	b := instr.Operands[1]
+	bits := uint64(instr.Type.Size() * 8)
+	if b.Class == hirc.Lit && b.Num >= bits {
+		return eu.NewCheckWarning(EC.ShiftOverflow, instr, &b,
+			"shift amount "+b.String()+" is not less than the width of "+instr.Type.String()+" ("+strconv.FormatUint(bits, 10)+")")
+	}
+	return nil
+}
+
+func selfComparison(instr *pir.Instr) *Error {
+	if len(instr.Operands) != 2 {
+		return nil
+	}
+	a := instr.Operands[0]
+	b := instr.Operands[1]
+	if a.Class != hirc.Lit && a.Class == b.Class && a.Num == b.Num {
+		return eu.NewCheckWarning(EC.SelfComparison, instr, &a, "comparison of "+a.String()+" with itself")
+	}
+	return nil
+}
+
+func uselessConvert(instr *pir.Instr) *Error {
+	if len(instr.Operands) != 1 || instr.Type == nil || instr.Operands[0].Type == nil {
+		return nil
+	}
+	a := instr.Operands[0]
+	if T.IsBasic(a.Type) && T.IsBasic(instr.Type) && a.Type.Basic == instr.Type.Basic {
+		return eu.NewCheckWarning(EC.UselessConvert, instr, &a, "conversion of "+a.String()+" to its own type")
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package liveness
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+	hirc "github.com/padeir0/pir/class"
+
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Backward dataflow liveness of temps, locals and arguments.
+
+A value is live at a point if it may be read on some path from that
+point before being written. Flow operands are read at the end of their
+block, after every instruction. Literals and globals are never live.
+*/
+
+type Value struct {
+	Class hirc.Class
+	Num   uint64
+}
+
+func (v Value) String() string {
+	return v.Class.String() + "#" + strconv.FormatUint(v.Num, 10)
+}
+
+// ValueOf returns the value of op, ok is false
+// if op is not a temp, local or argument.
+func ValueOf(op pir.Operand) (v Value, ok bool) {
+	switch op.Class {
+	case hirc.Temp, hirc.Local, hirc.Arg:
+		return Value{Class: op.Class, Num: op.Num}, true
+	}
+	return Value{}, false
+}
+
+type Set map[Value]bool
+
+func (s Set) Has(v Value) bool {
+	return s[v]
+}
+
+func (s Set) Copy() Set {
+	out := make(Set, len(s))
+	for v := range s {
+		out[v] = true
+	}
+	return out
+}
+
+func (s Set) Equals(other Set) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for v := range s {
+		if !other[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// sorted, so that the output is stable
+func (s Set) String() string {
+	output := make([]string, 0, len(s))
+	for v := range s {
+		output = append(output, v.String())
+	}
+	sort.Strings(output)
+	return "{" + strings.Join(output, ", ") + "}"
+}
+
+type Liveness struct {
+	proc *pir.Procedure
+	// values live at the start and at the end of each block,
+	// nil blocks have empty sets
+	In  []Set
+	Out []Set
+
+	// sets of each block, computed on first use by Block
+	blocks [][]Set
+}
+
+// New computes the liveness of every block of proc.
+func New(proc *pir.Procedure) *Liveness {
+	g := cfg.New(proc)
+	n := len(proc.AllBlocks)
+	l := &Liveness{
+		proc:   proc,
+		In:     make([]Set, n),
+		Out:    make([]Set, n),
+		blocks: make([][]Set, n),
+	}
+	for i := 0; i < n; i++ {
+		l.In[i] = Set{}
+		l.Out[i] = Set{}
+	}
+	// post-order converges faster for backward problems,
+	// unreachable blocks are analysed as well
+	order := append([]pir.BlockID{}, g.PostOrder...)
+	for i := 0; i < n; i++ {
+		if !g.Reachable[i] {
+			order = append(order, pir.BlockID(i))
+		}
+	}
+	changed := true
+	for changed {
+		changed = false
+		for _, id := range order {
+			bb := proc.AllBlocks[id]
+			if bb == nil {
+				continue
+			}
+			out := Set{}
+			for _, succ := range g.Succs[id] {
+				for v := range l.In[succ] {
+					out[v] = true
+				}
+			}
+			in := transfer(bb, out, nil)
+			if !in.Equals(l.In[id]) || !out.Equals(l.Out[id]) {
+				l.In[id] = in
+				l.Out[id] = out
+				changed = true
+			}
+		}
+	}
+	return l
+}
+
+// Block returns the values live before each instruction of the block,
+// the last set is the one live before the flow. The sets are computed
+// once and shared, they must not be modified.
+func (this *Liveness) Block(id pir.BlockID) []Set {
+	bb := this.proc.AllBlocks[id]
+	if bb == nil {
+		return nil
+	}
+	if this.blocks[id] == nil {
+		sets := make([]Set, len(bb.Code)+1)
+		transfer(bb, this.Out[id], sets)
+		this.blocks[id] = sets
+	}
+	return this.blocks[id]
+}
+
+// LiveBefore returns the values live before the instruction at index,
+// index may be len(Code), for the flow. The set is a copy.
+func (this *Liveness) LiveBefore(id pir.BlockID, index int) Set {
+	return this.Block(id)[index].Copy()
+}
+
+// LiveAfter returns the values live after the instruction at index,
+// index may be len(Code), for the flow. The set is a copy.
+func (this *Liveness) LiveAfter(id pir.BlockID, index int) Set {
+	sets := this.Block(id)
+	if index+1 < len(sets) {
+		return sets[index+1].Copy()
+	}
+	return this.Out[id].Copy()
+}
+
+// IsLiveOut reports whether v is live at the end of block id.
+func (this *Liveness) IsLiveOut(id pir.BlockID, v Value) bool {
+	return this.Out[id].Has(v)
+}
+
+// walks the block backwards from the values live at its end,
+// and returns the values live at its start. If sets is not nil,
+// it's filled with the values live before each instruction and the flow.
+func transfer(bb *pir.BasicBlock, out Set, sets []Set) Set {
+	live := out.Copy()
+	use(live, bb.Out.V)
+	if sets != nil {
+		sets[len(bb.Code)] = live.Copy()
+	}
+	for i := len(bb.Code) - 1; i >= 0; i-- {
+		instr := bb.Code[i]
+		def(live, instr.Destination)
+		use(live, instr.Operands)
+		if sets != nil {
+			sets[i] = live.Copy()
+		}
+	}
+	return live
+}
+
+func use(live Set, ops []pir.Operand) {
+	for _, op := range ops {
+		if v, ok := ValueOf(op); ok {
+			live[v] = true
+		}
+	}
+}
+
+func def(live Set, dests []pir.Operand) {
+	for _, dest := range dests {
+		if v, ok := ValueOf(dest); ok {
+			delete(live, v)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package liveness
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	"github.com/padeir0/pir/parse"
+
+	"fmt"
+	"testing"
+)
+
+const count = `Program: count
+
+count{
+i64
+i64
+i64
+}:
+b0:
+	copy:i64 0 -> local#0:i64
+	jmp .L1
+b1:
+	less:i64 local#0:i64, arg#0:i64 -> '0:bool
+	if '0:bool? .L2 : .L3
+b2:
+	add:i64 local#0:i64, 1 -> local#0:i64
+	jmp .L1
+b3:
+	ret local#0:i64
+`
+
+// both 1 and 2 are entered from 0, 4 is unreachable
+const irreducible = `Program: irreducible
+
+irr{
+bool
+i64
+i64, i64
+}:
+b0:
+	copy:i64 1 -> local#1:i64
+	if arg#0:bool? .L1 : .L2
+b1:
+	add:i64 local#0:i64, local#1:i64 -> local#0:i64
+	jmp .L2
+b2:
+	less:i64 local#0:i64, 10 -> '0:bool
+	if '0:bool? .L1 : .L3
+b3:
+	ret local#0:i64
+b4:
+	ret local#1:i64
+`
+
+func procedure(t *testing.T, input string) *pir.Procedure {
+	P, err := parse.Program(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return P.Symbols[0].Proc
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		in    string
+		out   string
+	}{
+		{
+			"loop",
+			count,
+			"[{arg#0} {arg#0, local#0} {arg#0, local#0} {local#0}]",
+			"[{arg#0, local#0} {arg#0, local#0} {arg#0, local#0} {}]",
+		},
+		{
+			"irreducible",
+			irreducible,
+			"[{arg#0, local#0} {local#0, local#1} {local#0, local#1} {local#0} {local#1}]",
+			"[{local#0, local#1} {local#0, local#1} {local#0, local#1} {} {}]",
+		},
+	}
+	for _, tt := range tests {
+		l := New(procedure(t, tt.input))
+		if got := fmt.Sprint(l.In); got != tt.in {
+			t.Errorf("%v: In is %v, expected %v", tt.name, got, tt.in)
+		}
+		if got := fmt.Sprint(l.Out); got != tt.out {
+			t.Errorf("%v: Out is %v, expected %v", tt.name, got, tt.out)
+		}
+	}
+}
+
+func TestInstrs(t *testing.T) {
+	l := New(procedure(t, count))
+	tests := []struct {
+		id     pir.BlockID
+		index  int
+		before string
+		after  string
+	}{
+		{0, 0, "{arg#0}", "{arg#0, local#0}"},
+		{0, 1, "{arg#0, local#0}", "{arg#0, local#0}"}, // the flow
+		{1, 0, "{arg#0, local#0}", "{arg#0, local#0, temp#0}"},
+		{1, 1, "{arg#0, local#0, temp#0}", "{arg#0, local#0}"},
+		{2, 0, "{arg#0, local#0}", "{arg#0, local#0}"},
+		{3, 0, "{local#0}", "{}"},
+	}
+	for _, tt := range tests {
+		if got := l.LiveBefore(tt.id, tt.index).String(); got != tt.before {
+			t.Errorf("LiveBefore(%v, %v) is %v, expected %v", tt.id, tt.index, got, tt.before)
+		}
+		if got := l.LiveAfter(tt.id, tt.index).String(); got != tt.after {
+			t.Errorf("LiveAfter(%v, %v) is %v, expected %v", tt.id, tt.index, got, tt.after)
+		}
+	}
+	if !l.IsLiveOut(2, Value{Class: hirc.Local, Num: 0}) || l.IsLiveOut(3, Value{Class: hirc.Local, Num: 0}) {
+		t.Errorf("IsLiveOut is wrong")
+	}
+}
+
+// the cached sets are shared, LiveBefore and LiveAfter are not
+func TestCopies(t *testing.T) {
+	l := New(procedure(t, count))
+	temp := Value{Class: hirc.Temp, Num: 9}
+	l.LiveBefore(1, 0)[temp] = true
+	l.LiveAfter(1, 0)[temp] = true
+	l.LiveAfter(1, 1)[temp] = true
+	for i, set := range l.Block(1) {
+		if set.Has(temp) {
+			t.Errorf("set %v of the block was modified", i)
+		}
+	}
+	if l.Out[1].Has(temp) {
+		t.Errorf("Out was modified")
+	}
+}
@@ -0,0 +1,237 @@
+package loops
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+	"github.com/padeir0/pir/dom"
+	. "github.com/padeir0/pir/errors"
+	EC "github.com/padeir0/pir/errors/code"
+	eu "github.com/padeir0/pir/errors/util"
+
+	"sort"
+	"strconv"
+)
+
+/*
+Natural loops and the loop nesting forest of a procedure.
+
+A back edge goes from a latch to a header that dominates it, the loop of
+a header is the header together with every block that reaches one of its
+latches without going through the header. Back edges to the same header
+form a single loop.
+
+Control flow is irreducible if some cycle is entered by more than one
+block, those cycles have no header and are not loops. They're found as
+retreating edges of a depth first search that are not back edges.
+*/
+
+type Loop struct {
+	Header  pir.BlockID
+	Latches []pir.BlockID
+	// sorted, includes the header and the blocks of inner loops
+	Body []pir.BlockID
+	// edges leaving the loop
+	Exits []cfg.Edge
+
+	Parent   *Loop // nil if outermost
+	Children []*Loop
+	// outermost loops have depth 1
+	Depth int
+
+	contains map[pir.BlockID]bool
+}
+
+func (this *Loop) Contains(id pir.BlockID) bool {
+	return this.contains[id]
+}
+
+func (this *Loop) String() string {
+	return "loop .L" + strconv.Itoa(int(this.Header)) + " (depth " + strconv.Itoa(this.Depth) + ")"
+}
+
+type Forest struct {
+	// outer loops come before inner loops
+	Loops []*Loop
+	Roots []*Loop
+	// innermost loop of each block, nil if not in a loop
+	LoopOf []*Loop
+	// retreating edges that are not back edges,
+	// empty if the control flow is reducible
+	Irreducible []cfg.Edge
+}
+
+// Depth returns the number of loops containing the block.
+func (this *Forest) Depth(id pir.BlockID) int {
+	if this.LoopOf[id] == nil {
+		return 0
+	}
+	return this.LoopOf[id].Depth
+}
+
+func (this *Forest) IsReducible() bool {
+	return len(this.Irreducible) == 0
+}
+
+// New finds the loops of proc.
+func New(proc *pir.Procedure) *Forest {
+	g := cfg.New(proc)
+	tree := dom.New(proc)
+	f := &Forest{
+		Loops:       []*Loop{},
+		Roots:       []*Loop{},
+		LoopOf:      make([]*Loop, len(proc.AllBlocks)),
+		Irreducible: []cfg.Edge{},
+	}
+	byHeader := map[pir.BlockID]*Loop{}
+	for _, from := range g.ReversePostOrder {
+		for _, to := range g.Succs[from] {
+			if !tree.Dominates(to, from) {
+				continue
+			}
+			loop, ok := byHeader[to]
+			if !ok {
+				loop = &Loop{Header: to, contains: map[pir.BlockID]bool{}}
+				byHeader[to] = loop
+			}
+			loop.Latches = append(loop.Latches, from)
+		}
+	}
+	for _, id := range g.ReversePostOrder {
+		loop, ok := byHeader[id]
+		if ok {
+			findBody(g, loop)
+			f.Loops = append(f.Loops, loop)
+		}
+	}
+	nest(f)
+	for _, e := range retreatingEdges(g) {
+		if !tree.Dominates(e.To, e.From) {
+			f.Irreducible = append(f.Irreducible, e)
+		}
+	}
+	return f
+}
+
+// Check reports irreducible control flow in P as warnings.
+func Check(P *pir.Program) []*Error {
+	diags := []*Error{}
+	for _, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil || sy.Builtin || sy.Extern {
+			continue
+		}
+		f := New(sy.Proc)
+		for _, e := range f.Irreducible {
+			diags = append(diags, irreducible(sy.Proc, e))
+		}
+	}
+	return diags
+}
+
+// walks the predecessors backwards from the latches, stopping at the header
+func findBody(g *cfg.CFG, loop *Loop) {
+	loop.contains[loop.Header] = true
+	stack := []pir.BlockID{}
+	for _, latch := range loop.Latches {
+		if !loop.contains[latch] {
+			loop.contains[latch] = true
+			stack = append(stack, latch)
+		}
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, pred := range g.Preds[id] {
+			if g.Reachable[pred] && !loop.contains[pred] {
+				loop.contains[pred] = true
+				stack = append(stack, pred)
+			}
+		}
+	}
+	for id := range loop.contains {
+		loop.Body = append(loop.Body, id)
+	}
+	sort.Slice(loop.Body, func(i, j int) bool { return loop.Body[i] < loop.Body[j] })
+	for _, id := range loop.Body {
+		for _, succ := range g.Succs[id] {
+			if !loop.contains[succ] {
+				loop.Exits = append(loop.Exits, cfg.Edge{From: id, To: succ})
+			}
+		}
+	}
+}
+
+// the parent of a loop is the smallest loop that contains its header,
+// since outer loops come first, parents are nested before their children
+func nest(f *Forest) {
+	for i, loop := range f.Loops {
+		for _, other := range f.Loops[:i] {
+			if !other.Contains(loop.Header) {
+				continue
+			}
+			if loop.Parent == nil || len(other.Body) < len(loop.Parent.Body) {
+				loop.Parent = other
+			}
+		}
+		if loop.Parent == nil {
+			loop.Depth = 1
+			f.Roots = append(f.Roots, loop)
+		} else {
+			loop.Depth = loop.Parent.Depth + 1
+			loop.Parent.Children = append(loop.Parent.Children, loop)
+		}
+		// inner loops overwrite outer ones
+		for _, id := range loop.Body {
+			f.LoopOf[id] = loop
+		}
+	}
+}
+
+// edges to a block that is still being visited in a depth first search
+func retreatingEdges(g *cfg.CFG) []cfg.Edge {
+	type frame struct {
+		id   pir.BlockID
+		next int
+	}
+	output := []cfg.Edge{}
+	n := len(g.Succs)
+	if g.Start < 0 || int(g.Start) >= n {
+		return output
+	}
+	visited := make([]bool, n)
+	onStack := make([]bool, n)
+	stack := []frame{{id: g.Start}}
+	visited[g.Start] = true
+	onStack[g.Start] = true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		succs := g.Succs[top.id]
+		if top.next < len(succs) {
+			succ := succs[top.next]
+			top.next++
+			if onStack[succ] {
+				output = append(output, cfg.Edge{From: top.id, To: succ})
+			} else if !visited[succ] {
+				visited[succ] = true
+				onStack[succ] = true
+				stack = append(stack, frame{id: succ})
+			}
+			continue
+		}
+		onStack[top.id] = false
+		stack = stack[:len(stack)-1]
+	}
+	return output
+}
+
+func irreducible(proc *pir.Procedure, e cfg.Edge) *Error {
+	bb := proc.AllBlocks[e.From]
+	err := eu.NewCheckWarning(EC.IrreducibleFlow, &bb.Out, nil,
+		"irreducible control flow: edge from .L"+strconv.Itoa(int(e.From))+" to .L"+strconv.Itoa(int(e.To))+" enters a cycle without a header")
+	err.Proc = proc.Label
+	err.Block = int(e.From)
+	sp := bb.Out.Span
+	if sp == nil {
+		sp = proc.Span
+	}
+	return eu.WithSpan(sp, err)
+}
@@ -0,0 +1,151 @@
+package loops
+
+import (
+	"github.com/padeir0/pir"
+	EC "github.com/padeir0/pir/errors/code"
+	sv "github.com/padeir0/pir/errors/severity"
+	"github.com/padeir0/pir/util"
+
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// blocks without successors return, blocks with one jump
+// and blocks with two branch
+func proc(succs ...[]pir.BlockID) *pir.Procedure {
+	cond := util.BoolLit(true)
+	bbs := make([]*pir.BasicBlock, len(succs))
+	for i, s := range succs {
+		bbs[i] = &pir.BasicBlock{Label: "b" + strconv.Itoa(i)}
+		switch len(s) {
+		case 0:
+			bbs[i].Out = util.Return()
+		case 1:
+			bbs[i].Out = util.Jmp(s[0])
+		default:
+			bbs[i].Out = util.Branch(cond, s[0], s[1])
+		}
+	}
+	return &pir.Procedure{Label: "p", AllBlocks: bbs}
+}
+
+func ids(list ...pir.BlockID) []pir.BlockID { return list }
+
+func describe(f *Forest) string {
+	output := []string{}
+	for _, l := range f.Loops {
+		parent := "-"
+		if l.Parent != nil {
+			parent = ".L" + strconv.Itoa(int(l.Parent.Header))
+		}
+		output = append(output, fmt.Sprint(".L", l.Header, " latches ", l.Latches,
+			" body ", l.Body, " exits ", l.Exits, " parent ", parent))
+	}
+	return strings.Join(output, "; ")
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		proc        *pir.Procedure
+		loops       string
+		depths      string
+		irreducible string
+	}{
+		{
+			// 0 -> 1 ; 1 -> 2 | 3 ; 2 -> 4 ; 3 -> 4 | 7 ; 4 -> 1 | 5 ; 5 returns
+			// 6 -> 5 is unreachable and 7 is an infinite loop
+			"classic",
+			proc(ids(1), ids(2, 3), ids(4), ids(4, 7), ids(1, 5), ids(), ids(5), ids(7)),
+			".L1 latches [4] body [1 2 3 4] exits [{3 7} {4 5}] parent -; " +
+				".L7 latches [7] body [7] exits [] parent -",
+			"[0 1 1 1 1 0 0 1]",
+			"[]",
+		},
+		{
+			// 0 -> 1 ; 1 -> 2 | 5 ; 2 -> 3 ; 3 -> 3 | 4 ; 4 -> 1 | 2 ; 5 returns
+			"nested",
+			proc(ids(1), ids(2, 5), ids(3), ids(3, 4), ids(1, 2), ids()),
+			".L1 latches [4] body [1 2 3 4] exits [{1 5}] parent -; " +
+				".L2 latches [4] body [2 3 4] exits [{4 1}] parent .L1; " +
+				".L3 latches [3] body [3] exits [{3 4}] parent .L2",
+			"[0 1 2 3 2 0]",
+			"[]",
+		},
+		{
+			// two latches, 2 -> 1 and 3 -> 1, in reverse post-order
+			"two latches",
+			proc(ids(1), ids(2, 3), ids(1, 4), ids(1), ids()),
+			".L1 latches [3 2] body [1 2 3] exits [{2 4}] parent -",
+			"[0 1 1 1 0]",
+			"[]",
+		},
+		{
+			// both 1 and 2 are entered from 0
+			"irreducible",
+			proc(ids(1, 2), ids(2), ids(1, 3), ids()),
+			"",
+			"[0 0 0 0]",
+			"[{2 1}]",
+		},
+		{
+			// 0 -> 1 ; 1 -> 0 | 2 ; 2 returns
+			"loop through the start",
+			proc(ids(1), ids(0, 2), ids()),
+			".L0 latches [1] body [0 1] exits [{1 2}] parent -",
+			"[1 1 0]",
+			"[]",
+		},
+		{
+			"self loop on the start",
+			proc(ids(0, 1), ids()),
+			".L0 latches [0] body [0] exits [{0 1}] parent -",
+			"[1 0]",
+			"[]",
+		},
+	}
+	for _, tt := range tests {
+		f := New(tt.proc)
+		if got := describe(f); got != tt.loops {
+			t.Errorf("%v: loops are %v, expected %v", tt.name, got, tt.loops)
+		}
+		depths := make([]int, len(tt.proc.AllBlocks))
+		for i := range depths {
+			depths[i] = f.Depth(pir.BlockID(i))
+		}
+		if got := fmt.Sprint(depths); got != tt.depths {
+			t.Errorf("%v: depths are %v, expected %v", tt.name, got, tt.depths)
+		}
+		if got := fmt.Sprint(f.Irreducible); got != tt.irreducible {
+			t.Errorf("%v: irreducible edges are %v, expected %v", tt.name, got, tt.irreducible)
+		}
+		if f.IsReducible() != (len(f.Irreducible) == 0) {
+			t.Errorf("%v: IsReducible is wrong", tt.name)
+		}
+		for _, l := range f.Loops {
+			for _, id := range l.Body {
+				if !l.Contains(id) {
+					t.Errorf("%v: %v doesn't contain %v", tt.name, l, id)
+				}
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	P := pir.NewProgram()
+	P.AddProc(proc(ids(1), ids(2, 5), ids(3), ids(3, 4), ids(1, 2), ids()))
+	irr := proc(ids(1, 2), ids(2), ids(1, 3), ids())
+	irr.Label = "irr"
+	P.AddProc(irr)
+	diags := Check(P)
+	if len(diags) != 1 {
+		t.Fatalf("expected a single diagnostic, got %v", diags)
+	}
+	d := diags[0]
+	if d.Code != EC.IrreducibleFlow || d.Severity != sv.Warning || d.Proc != "irr" || d.Block != 2 {
+		t.Errorf("unexpected diagnostic: %v", d)
+	}
+}
@@ -0,0 +1,222 @@
+package common
+
+import (
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	lk "github.com/padeir0/pir/parse/lexer"
+	T "github.com/padeir0/pir/types"
+
+	"strconv"
+	"strings"
+)
+
+/*
+Parsing helpers shared by the PIR and MIR text formats:
+the program header, the token cursor and types.
+*/
+
+// Header reads the program name, which is free form, so it's read
+// before lexing. It returns the rest of the input and its first line.
+func Header(input string) (name string, rest string, line int) {
+	line = 1
+	rest = input
+	for {
+		i := strings.IndexByte(rest, '\n')
+		current := rest
+		if i >= 0 {
+			current = rest[:i]
+		}
+		trimmed := strings.TrimSpace(current)
+		if trimmed == "" && i >= 0 {
+			rest = rest[i+1:]
+			line++
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Program:") {
+			name = strings.TrimSpace(strings.TrimPrefix(trimmed, "Program:"))
+			if i < 0 {
+				return name, "", line
+			}
+			return name, rest[i+1:], line + 1
+		}
+		return "", rest, line
+	}
+}
+
+type Parser struct {
+	tokens []lk.Token
+	pos    int
+	// procedure types are interned, as if they were created by a frontend
+	types *T.Interner
+}
+
+func NewParser(tokens []lk.Token) *Parser {
+	return &Parser{tokens: tokens, types: T.NewInterner()}
+}
+
+func (p *Parser) Peek() lk.Token {
+	return p.tokens[p.pos]
+}
+
+func (p *Parser) PeekAt(offset int) lk.Token {
+	i := p.pos + offset
+	if i >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[i]
+}
+
+func (p *Parser) Next() lk.Token {
+	tk := p.tokens[p.pos]
+	if tk.Kind != lk.EOF {
+		p.pos++
+	}
+	return tk
+}
+
+func (p *Parser) Expect(kind lk.TkKind) (lk.Token, *Error) {
+	tk := p.Peek()
+	if tk.Kind != kind {
+		return tk, Expected(tk, kind.String())
+	}
+	return p.Next(), nil
+}
+
+func (p *Parser) ExpectKeyword(word string) (lk.Token, *Error) {
+	tk := p.Peek()
+	if tk.Kind != lk.Ident || tk.Text != word {
+		return tk, Expected(tk, "'"+word+"'")
+	}
+	return p.Next(), nil
+}
+
+func (p *Parser) Is(kind lk.TkKind) bool {
+	return p.Peek().Kind == kind
+}
+
+func (p *Parser) SkipNewlines() {
+	for p.Is(lk.Newline) {
+		p.Next()
+	}
+}
+
+// end of line or end of file
+func (p *Parser) ExpectEOL() *Error {
+	if p.Is(lk.EOF) {
+		return nil
+	}
+	_, err := p.Expect(lk.Newline)
+	return err
+}
+
+func (p *Parser) IsEOL() bool {
+	return p.Is(lk.Newline) || p.Is(lk.EOF)
+}
+
+// Types := [Type {',' Type}]
+func (p *Parser) Types() ([]*T.Type, *Error) {
+	output := []*T.Type{}
+	t, err := p.Type()
+	if err != nil {
+		return nil, err
+	}
+	output = append(output, t)
+	for p.Is(lk.Comma) {
+		p.Next()
+		t, err = p.Type()
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, t)
+	}
+	return output, nil
+}
+
+// Type := basic | 'MultiRet' | 'Void' | 'nil'
+// Type := 'proc' '[' Types ']' '[' Types ']'
+func (p *Parser) Type() (*T.Type, *Error) {
+	tk, err := p.Expect(lk.Ident)
+	if err != nil {
+		return nil, err
+	}
+	switch tk.Text {
+	case "proc":
+		args, err := p.typeList()
+		if err != nil {
+			return nil, err
+		}
+		rets, err := p.typeList()
+		if err != nil {
+			return nil, err
+		}
+		return p.types.Proc(args, rets), nil
+	case "nil":
+		return nil, nil
+	}
+	t, ok := T.Named(tk.Text)
+	if !ok {
+		return nil, Expected(tk, "type")
+	}
+	return t, nil
+}
+
+// TypeLine := [Types] NL
+func (p *Parser) TypeLine() ([]*T.Type, *Error) {
+	if p.Is(lk.Newline) {
+		p.Next()
+		return []*T.Type{}, nil
+	}
+	types, err := p.Types()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.Expect(lk.Newline)
+	if err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+func (p *Parser) typeList() ([]*T.Type, *Error) {
+	_, err := p.Expect(lk.LeftBracket)
+	if err != nil {
+		return nil, err
+	}
+	types := []*T.Type{}
+	if !p.Is(lk.RightBracket) {
+		types, err = p.Types()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.Expect(lk.RightBracket)
+	if err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+func Num(tk lk.Token) (uint64, *Error) {
+	num, err := strconv.ParseUint(tk.Text, 10, 64)
+	if err != nil {
+		return 0, eu.NewParseError(tk.Line, tk.Col, "number out of range: "+tk.Text)
+	}
+	return num, nil
+}
+
+// Lit reads a literal, negative ones are kept sign extended
+// like in util.IntLit
+func Lit(tk lk.Token) (uint64, *Error) {
+	if strings.HasPrefix(tk.Text, "-") {
+		num, err := strconv.ParseInt(tk.Text, 10, 64)
+		if err != nil {
+			return 0, eu.NewParseError(tk.Line, tk.Col, "number out of range: "+tk.Text)
+		}
+		return uint64(num), nil
+	}
+	return Num(tk)
+}
+
+func Expected(tk lk.Token, what string) *Error {
+	return eu.NewParseError(tk.Line, tk.Col, "expected "+what+", instead found "+tk.String())
+}
@@ -0,0 +1,249 @@
+package lexer
+
+import (
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+
+	"strconv"
+)
+
+type TkKind int
+
+func (t TkKind) String() string {
+	switch t {
+	case EOF:
+		return "end of file"
+	case Newline:
+		return "newline"
+	case Ident:
+		return "identifier"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Colon:
+		return "':'"
+	case Comma:
+		return "','"
+	case LeftBrace:
+		return "'{'"
+	case RightBrace:
+		return "'}'"
+	case LeftBracket:
+		return "'['"
+	case RightBracket:
+		return "']'"
+	case Quote:
+		return "'''"
+	case Hash:
+		return "'#'"
+	case Arrow:
+		return "'->'"
+	case Question:
+		return "'?'"
+	}
+	return "invalid token"
+}
+
+const (
+	InvalidTk TkKind = iota
+
+	EOF
+	Newline
+	Ident
+	Number
+	String
+	Colon
+	Comma
+	LeftBrace
+	RightBrace
+	LeftBracket
+	RightBracket
+	Quote
+	Hash
+	Arrow
+	Question
+)
+
+type Token struct {
+	Kind TkKind
+	Text string
+	Line int
+	Col  int
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case Ident, Number, String:
+		return t.Kind.String() + " '" + t.Text + "'"
+	}
+	return t.Kind.String()
+}
+
+func (t Token) Pos() string {
+	return strconv.Itoa(t.Line) + ":" + strconv.Itoa(t.Col)
+}
+
+// Lex splits the input into tokens, the last token is always EOF.
+// Spaces and tabs are ignored, newlines are significant and
+// comments start with ';' and go until the end of the line.
+// Positions are counted starting at the given line.
+func Lex(input string, line int) ([]Token, *Error) {
+	l := &lexer{input: input, line: line, col: 1}
+	output := []Token{}
+	for {
+		tk, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, tk)
+		if tk.Kind == EOF {
+			return output, nil
+		}
+	}
+}
+
+type lexer struct {
+	input string
+	pos   int
+	line  int
+	col   int
+}
+
+func (l *lexer) peekByte() byte {
+	if l.pos >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos]
+}
+
+func (l *lexer) advance() byte {
+	b := l.input[l.pos]
+	l.pos++
+	if b == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return b
+}
+
+func (l *lexer) next() (Token, *Error) {
+	l.ignoreWhitespace()
+	tk := Token{Line: l.line, Col: l.col}
+	if l.pos >= len(l.input) {
+		tk.Kind = EOF
+		return tk, nil
+	}
+	start := l.pos
+	b := l.advance()
+	switch b {
+	case '\n':
+		tk.Kind = Newline
+	case ':':
+		tk.Kind = Colon
+	case ',':
+		tk.Kind = Comma
+	case '{':
+		tk.Kind = LeftBrace
+	case '}':
+		tk.Kind = RightBrace
+	case '[':
+		tk.Kind = LeftBracket
+	case ']':
+		tk.Kind = RightBracket
+	case '\'':
+		tk.Kind = Quote
+	case '#':
+		tk.Kind = Hash
+	case '?':
+		tk.Kind = Question
+	case '-':
+		if isDigit(l.peekByte()) {
+			// negative literals
+			for isDigit(l.peekByte()) {
+				l.advance()
+			}
+			tk.Kind = Number
+			break
+		}
+		if l.peekByte() != '>' {
+			return tk, unexpectedChar(tk, b)
+		}
+		l.advance()
+		tk.Kind = Arrow
+	case '"':
+		err := l.str(tk)
+		if err != nil {
+			return tk, err
+		}
+		tk.Kind = String
+	default:
+		if isDigit(b) {
+			for isDigit(l.peekByte()) {
+				l.advance()
+			}
+			tk.Kind = Number
+		} else if isIdentStart(b) {
+			for isIdentStart(l.peekByte()) || isDigit(l.peekByte()) {
+				l.advance()
+			}
+			tk.Kind = Ident
+		} else {
+			return tk, unexpectedChar(tk, b)
+		}
+	}
+	tk.Text = l.input[start:l.pos]
+	return tk, nil
+}
+
+// strings are kept as they are written, escapes included
+func (l *lexer) str(tk Token) *Error {
+	for {
+		switch l.peekByte() {
+		case 0, '\n':
+			return eu.NewParseError(tk.Line, tk.Col, "unterminated string")
+		case '\\':
+			l.advance()
+			if l.peekByte() == 0 || l.peekByte() == '\n' {
+				return eu.NewParseError(tk.Line, tk.Col, "unterminated string")
+			}
+			l.advance()
+		case '"':
+			l.advance()
+			return nil
+		default:
+			l.advance()
+		}
+	}
+}
+
+func (l *lexer) ignoreWhitespace() {
+	for l.pos < len(l.input) {
+		switch l.peekByte() {
+		case ' ', '\t', '\r':
+			l.advance()
+		case ';':
+			for l.pos < len(l.input) && l.peekByte() != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isIdentStart(b byte) bool {
+	return (b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z') ||
+		b == '_' || b == '.' || b == '$' || b == '@'
+}
+
+func unexpectedChar(tk Token, b byte) *Error {
+	return eu.NewParseError(tk.Line, tk.Col, "unexpected character: "+strconv.QuoteRune(rune(b)))
+}
@@ -0,0 +1,540 @@
+package parse
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	FT "github.com/padeir0/pir/flowkind"
+	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/parse/common"
+	lk "github.com/padeir0/pir/parse/lexer"
+	T "github.com/padeir0/pir/types"
+
+	"strconv"
+	"strings"
+)
+
+// Program parses the text printed by pir.Program.String() back into
+// a program.
+//
+// The printed text says nothing about starting blocks or entry points,
+// so every procedure starts at its first block and the entry point
+// is the symbol labeled "main", if there's one, or pir.NoEntry.
+//
+// Literals may be annotated with a type (10:i32), if they aren't,
+// the type is inferred from the instruction or flow they're used in.
+func Program(input string) (*pir.Program, *Error) {
+	name, rest, line := common.Header(input)
+	tokens, err := lk.Lex(rest, line)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		Parser:  common.NewParser(tokens),
+		program: pir.NewProgram(),
+	}
+	p.program.Name = name
+	err = parseSymbols(p)
+	if err != nil {
+		return nil, err
+	}
+	p.program.Entry = pir.NoEntry
+	for i, sy := range p.program.Symbols {
+		if sy.Proc != nil && sy.Proc.Label == "main" {
+			p.program.Entry = pir.SymbolID(i)
+			break
+		}
+	}
+	return p.program, nil
+}
+
+type parser struct {
+	*common.Parser
+
+	program *pir.Program
+	proc    *pir.Procedure
+}
+
+// Symbols := {Symbol | NL} EOF
+func parseSymbols(p *parser) *Error {
+	for {
+		p.SkipNewlines()
+		if p.Is(lk.EOF) {
+			return nil
+		}
+		err := parseSymbol(p)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Symbol := Procedure | label ':' ('builtin' | Extern | number | string)
+func parseSymbol(p *parser) *Error {
+	label, err := p.Expect(lk.Ident)
+	if err != nil {
+		return err
+	}
+	if p.Is(lk.LeftBrace) {
+		return parseProc(p, label)
+	}
+	_, err = p.Expect(lk.Colon)
+	if err != nil {
+		return err
+	}
+	tk := p.Next()
+	switch tk.Kind {
+	case lk.Ident:
+		switch tk.Text {
+		case "builtin":
+			p.program.AddBuiltin(&pir.Procedure{Label: label.Text})
+		case "extern":
+			err := parseExtern(p, label)
+			if err != nil {
+				return err
+			}
+		default:
+			return common.Expected(tk, "'builtin' or 'extern'")
+		}
+	case lk.Number:
+		size, err := common.Num(tk)
+		if err != nil {
+			return err
+		}
+		p.program.AddMem(&pir.MemoryDecl{Label: label.Text, Size: size})
+	case lk.String:
+		p.program.AddMem(&pir.MemoryDecl{Label: label.Text, Data: tk.Text})
+	default:
+		return common.Expected(tk, "'builtin', 'extern', number or string")
+	}
+	return p.ExpectEOL()
+}
+
+// Extern := 'extern' ('mem' | ProcType)
+func parseExtern(p *parser, label lk.Token) *Error {
+	tk := p.Peek()
+	if tk.Kind == lk.Ident && tk.Text == "mem" {
+		p.Next()
+		p.program.AddExternMem(&pir.MemoryDecl{Label: label.Text})
+		return nil
+	}
+	t, err := p.Type()
+	if err != nil {
+		return err
+	}
+	if !T.IsProc(t) {
+		return common.Expected(tk, "'mem' or procedure type")
+	}
+	p.program.AddExternProc(&pir.Procedure{
+		Label: label.Text,
+		Args:  t.Proc.Args,
+		Rets:  t.Proc.Rets,
+	})
+	return nil
+}
+
+// Procedure := label '{' NL Types NL Types NL Types NL '}' ':' NL {Block}
+func parseProc(p *parser, label lk.Token) *Error {
+	p.Next() // '{'
+	_, err := p.Expect(lk.Newline)
+	if err != nil {
+		return err
+	}
+	proc := &pir.Procedure{
+		Label:     label.Text,
+		Start:     0,
+		AllBlocks: []*pir.BasicBlock{},
+	}
+	p.proc = proc
+
+	proc.Args, err = p.TypeLine()
+	if err != nil {
+		return err
+	}
+	proc.Rets, err = p.TypeLine()
+	if err != nil {
+		return err
+	}
+	proc.Vars, err = p.TypeLine()
+	if err != nil {
+		return err
+	}
+	_, err = p.Expect(lk.RightBrace)
+	if err != nil {
+		return err
+	}
+	_, err = p.Expect(lk.Colon)
+	if err != nil {
+		return err
+	}
+	err = p.ExpectEOL()
+	if err != nil {
+		return err
+	}
+
+	for {
+		p.SkipNewlines()
+		if !isBlockStart(p) {
+			break
+		}
+		bb, err := parseBlock(p)
+		if err != nil {
+			return err
+		}
+		proc.AllBlocks = append(proc.AllBlocks, bb)
+	}
+	p.program.AddProc(proc)
+	p.proc = nil
+	return nil
+}
+
+// a block starts with 'label:' alone in a line,
+// memory declarations and builtins always have something after the colon
+func isBlockStart(p *parser) bool {
+	if p.Peek().Kind != lk.Ident || p.PeekAt(1).Kind != lk.Colon {
+		return false
+	}
+	next := p.PeekAt(2).Kind
+	return next == lk.Newline || next == lk.EOF
+}
+
+// Block := label ':' NL {Instr NL} Flow EOL
+func parseBlock(p *parser) (*pir.BasicBlock, *Error) {
+	label := p.Next()
+	p.Next() // ':'
+	p.Next() // NL
+	bb := &pir.BasicBlock{
+		Label: label.Text,
+		Code:  []pir.Instr{},
+	}
+	for {
+		p.SkipNewlines()
+		if isFlowStart(p) {
+			break
+		}
+		instr, err := parseInstr(p)
+		if err != nil {
+			return nil, err
+		}
+		bb.AddInstr(instr)
+		err = p.ExpectEOL()
+		if err != nil {
+			return nil, err
+		}
+	}
+	flow, err := parseFlow(p)
+	if err != nil {
+		return nil, err
+	}
+	bb.Out = flow
+	return bb, p.ExpectEOL()
+}
+
+func isFlowStart(p *parser) bool {
+	tk := p.Peek()
+	if tk.Kind != lk.Ident {
+		return tk.Kind == lk.EOF
+	}
+	switch tk.Text {
+	case "jmp", "if", "ret", "exit", "invalid":
+		return true
+	}
+	return false
+}
+
+// Flow := 'jmp' blockid | 'if' Operand '?' blockid ':' blockid
+// Flow := 'ret' [Operands] | 'exit' Operand | 'invalid' 'FlowType'
+func parseFlow(p *parser) (pir.Flow, *Error) {
+	tk := p.Peek()
+	if tk.Kind != lk.Ident {
+		return pir.Flow{}, common.Expected(tk, "flow")
+	}
+	p.Next()
+	switch tk.Text {
+	case "jmp":
+		id, err := parseBlockID(p)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		return pir.Flow{T: FT.Jmp, True: id}, nil
+	case "if":
+		cond, err := parseOperand(p)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		if cond.Class == hirc.Lit && cond.Type == nil {
+			cond.Type = T.T_Bool
+		}
+		_, err = p.Expect(lk.Question)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		t, err := parseBlockID(p)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		_, err = p.Expect(lk.Colon)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		f, err := parseBlockID(p)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		return pir.Flow{T: FT.If, V: []pir.Operand{cond}, True: t, False: f}, nil
+	case "ret":
+		rets := []pir.Operand{}
+		if !p.IsEOL() {
+			var err *Error
+			rets, err = parseOperands(p)
+			if err != nil {
+				return pir.Flow{}, err
+			}
+		}
+		for i := range rets {
+			if rets[i].Class == hirc.Lit && rets[i].Type == nil {
+				if i >= len(p.proc.Rets) {
+					return pir.Flow{}, cantInfer(tk)
+				}
+				rets[i].Type = p.proc.Rets[i]
+			}
+		}
+		return pir.Flow{T: FT.Return, V: rets}, nil
+	case "exit":
+		ops, err := parseOperands(p)
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		for i := range ops {
+			if ops[i].Class == hirc.Lit && ops[i].Type == nil {
+				ops[i].Type = T.T_I8
+			}
+		}
+		return pir.Flow{T: FT.Exit, V: ops}, nil
+	case "invalid":
+		_, err := p.ExpectKeyword("FlowType")
+		if err != nil {
+			return pir.Flow{}, err
+		}
+		return pir.Flow{T: FT.InvalidFlow}, nil
+	}
+	return pir.Flow{}, common.Expected(tk, "flow")
+}
+
+// blockid := '.L' number
+func parseBlockID(p *parser) (pir.BlockID, *Error) {
+	tk, err := p.Expect(lk.Ident)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(tk.Text, ".L") {
+		return 0, common.Expected(tk, "block id (.L<number>)")
+	}
+	id, convErr := strconv.ParseInt(tk.Text[2:], 10, 64)
+	if convErr != nil || id < 0 {
+		return 0, common.Expected(tk, "block id (.L<number>)")
+	}
+	return pir.BlockID(id), nil
+}
+
+// Instr := kind (':' Type | ',') [Operands] ['->' [Operands]]
+func parseInstr(p *parser) (pir.Instr, *Error) {
+	start, err := p.Expect(lk.Ident)
+	if err != nil {
+		return pir.Instr{}, err
+	}
+	kind, ok := instrKinds[start.Text]
+	if !ok {
+		return pir.Instr{}, common.Expected(start, "instruction")
+	}
+	instr := pir.Instr{T: kind}
+	if p.Is(lk.Colon) {
+		p.Next()
+		instr.Type, err = p.Type()
+		if err != nil {
+			return pir.Instr{}, err
+		}
+	} else {
+		_, err = p.Expect(lk.Comma)
+		if err != nil {
+			return pir.Instr{}, err
+		}
+	}
+	if !p.IsEOL() && !p.Is(lk.Arrow) {
+		instr.Operands, err = parseOperands(p)
+		if err != nil {
+			return pir.Instr{}, err
+		}
+	}
+	if p.Is(lk.Arrow) {
+		p.Next()
+		instr.Destination = []pir.Operand{}
+		if !p.IsEOL() {
+			instr.Destination, err = parseOperands(p)
+			if err != nil {
+				return pir.Instr{}, err
+			}
+		}
+	}
+	if !inferLiterals(&instr) {
+		return pir.Instr{}, cantInfer(start)
+	}
+	return instr, nil
+}
+
+var instrKinds = map[string]IT.InstrKind{}
+
+func init() {
+	for k := IT.Add; k <= IT.Call; k++ {
+		instrKinds[k.String()] = k
+	}
+}
+
+// returns false if a literal type could not be inferred
+func inferLiterals(instr *pir.Instr) bool {
+	for i := range instr.Operands {
+		op := &instr.Operands[i]
+		if op.Class != hirc.Lit || op.Type != nil {
+			continue
+		}
+		op.Type = operandType(instr, i)
+		if op.Type == nil {
+			return false
+		}
+	}
+	for i := range instr.Destination {
+		dest := &instr.Destination[i]
+		if dest.Class != hirc.Lit || dest.Type != nil {
+			continue
+		}
+		dest.Type = destType(instr, i)
+		if dest.Type == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func operandType(instr *pir.Instr, i int) *T.Type {
+	switch instr.T {
+	case IT.LoadPtr:
+		return T.T_Ptr
+	case IT.StorePtr:
+		if i == 1 {
+			return T.T_Ptr
+		}
+		return instr.Type
+	case IT.Convert:
+		return nil
+	case IT.Call:
+		if i == 0 {
+			return nil
+		}
+		procT := instr.Operands[0].Type
+		if procT == nil || !T.IsProc(procT) || i-1 >= len(procT.Proc.Args) {
+			return nil
+		}
+		return procT.Proc.Args[i-1]
+	}
+	return instr.Type
+}
+
+func destType(instr *pir.Instr, i int) *T.Type {
+	switch instr.T {
+	case IT.Eq, IT.Diff, IT.Less, IT.More, IT.LessEq, IT.MoreEq:
+		return T.T_Bool
+	case IT.Call:
+		procT := instr.Operands[0].Type
+		if procT == nil || !T.IsProc(procT) || i >= len(procT.Proc.Rets) {
+			return nil
+		}
+		return procT.Proc.Rets[i]
+	}
+	return instr.Type
+}
+
+// Operands := Operand {',' Operand}
+func parseOperands(p *parser) ([]pir.Operand, *Error) {
+	output := []pir.Operand{}
+	op, err := parseOperand(p)
+	if err != nil {
+		return nil, err
+	}
+	output = append(output, op)
+	for p.Is(lk.Comma) {
+		p.Next()
+		op, err = parseOperand(p)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, op)
+	}
+	return output, nil
+}
+
+// Operand := "'" number ':' Type | number [':' Type]
+// Operand := ('local' | 'arg' | 'global') '#' number ':' Type
+func parseOperand(p *parser) (pir.Operand, *Error) {
+	tk := p.Next()
+	switch tk.Kind {
+	case lk.Quote:
+		return parseTypedOperand(p, hirc.Temp)
+	case lk.Number:
+		num, err := common.Lit(tk)
+		if err != nil {
+			return pir.Operand{}, err
+		}
+		op := pir.Operand{Class: hirc.Lit, Num: num}
+		if p.Is(lk.Colon) {
+			p.Next()
+			op.Type, err = p.Type()
+			if err != nil {
+				return pir.Operand{}, err
+			}
+		}
+		return op, nil
+	case lk.Ident:
+		var class hirc.Class
+		switch tk.Text {
+		case "local":
+			class = hirc.Local
+		case "arg":
+			class = hirc.Arg
+		case "global":
+			class = hirc.Global
+		default:
+			return pir.Operand{}, common.Expected(tk, "operand")
+		}
+		_, err := p.Expect(lk.Hash)
+		if err != nil {
+			return pir.Operand{}, err
+		}
+		return parseTypedOperand(p, class)
+	}
+	return pir.Operand{}, common.Expected(tk, "operand")
+}
+
+func parseTypedOperand(p *parser, class hirc.Class) (pir.Operand, *Error) {
+	tk, err := p.Expect(lk.Number)
+	if err != nil {
+		return pir.Operand{}, err
+	}
+	num, err := common.Num(tk)
+	if err != nil {
+		return pir.Operand{}, err
+	}
+	_, err = p.Expect(lk.Colon)
+	if err != nil {
+		return pir.Operand{}, err
+	}
+	t, err := p.Type()
+	if err != nil {
+		return pir.Operand{}, err
+	}
+	return pir.Operand{Class: class, Num: num, Type: t}, nil
+}
+
+func cantInfer(tk lk.Token) *Error {
+	return eu.NewParseError(tk.Line, tk.Col, "can't infer literal type, annotate it (ex: 1:i64)")
+}
@@ -0,0 +1,193 @@
+package parse
+
+import (
+	"github.com/padeir0/pir"
+	EC "github.com/padeir0/pir/errors/code"
+
+	"strings"
+	"testing"
+)
+
+const square = `Program: square
+
+write: builtin
+msg: "hello\n"
+buff: 64
+ext: extern proc[i64][]
+data: extern mem
+square{
+i64, i32
+i64
+
+}:
+square_b0:
+	mult:i64 arg#0:i64, arg#0:i64 -> '0:i64
+	ret '0:i64
+
+
+main{
+
+
+i64, bool
+}:
+main_b0:
+	call, global#5:proc[i64, i32][i64], 5:i64, 2:i32 -> local#0:i64
+	less:i64 local#0:i64, 30:i64 -> '1:bool
+	if '1:bool? .L1 : .L2
+main_b1:
+	storeptr:i64 7:i64, global#2:ptr
+	jmp .L2
+main_b2:
+	exit 0:i8
+
+
+`
+
+const literals = `Program: literals
+
+main{
+
+
+i8, i64, u8
+}:
+main_b0:
+	copy:i8 -1 -> local#0:i8
+	copy:i64 -9223372036854775808 -> local#1:i64
+	add:i8 local#0:i8, -128 -> local#0:i8
+	copy:u8 255 -> local#2:u8
+	exit local#0:i8
+
+
+`
+
+const loop = `Program: loop
+
+count{
+i64
+i64
+i64
+}:
+b0:
+	copy:i64 0 -> local#0:i64
+	jmp .L1
+b1:
+	less:i64 local#0:i64, arg#0:i64 -> '0:bool
+	if '0:bool? .L2 : .L3
+b2:
+	add:i64 local#0:i64, 1 -> local#0:i64
+	jmp .L1
+b3:
+	ret local#0:i64
+
+
+`
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		entry pir.SymbolID
+	}{
+		{"square", square, 6},
+		{"literals", literals, 0},
+		{"loop", loop, pir.NoEntry},
+	}
+	for _, tt := range tests {
+		P, err := Program(tt.input)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if P.Entry != tt.entry {
+			t.Errorf("%v: entry is %v, expected %v", tt.name, P.Entry, tt.entry)
+		}
+		first := P.String()
+		Q, err := Program(first)
+		if err != nil {
+			t.Fatalf("%v: reparsing: %v", tt.name, err)
+		}
+		if second := Q.String(); first != second {
+			t.Errorf("%v: output differs after reparsing:\n%v\n%v", tt.name, first, second)
+		}
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	P, err := Program(literals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := P.Symbols[0].Proc.AllBlocks[0].Code
+	tests := []struct {
+		instr   int
+		operand int
+		num     uint64
+	}{
+		{0, 0, 1<<64 - 1},
+		{1, 0, 1 << 63},
+		{2, 1, 1<<64 - 128},
+		{3, 0, 255},
+	}
+	for _, tt := range tests {
+		op := code[tt.instr].Operands[tt.operand]
+		if op.Num != tt.num {
+			t.Errorf("%v: literal is %v, expected %v", code[tt.instr], op.Num, tt.num)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		message string
+	}{
+		{
+			"untyped literal",
+			"Program: x\nfoo{\n\n\n\n}:\nb0:\n\tadd:i64 '1:i64, 2 -> '3:i64\n\tconvert:i8 4 -> '1:i8\n\tret\n",
+			"can't infer literal type",
+		},
+		{
+			"bad block id",
+			"foo{\n\n\n\n}:\nb0:\n\tjmp L1\n",
+			"expected block id",
+		},
+		{
+			"unterminated string",
+			"x: \"abc\n",
+			"unterminated string",
+		},
+		{
+			"bad symbol",
+			"x: proc\n",
+			"'builtin' or 'extern'",
+		},
+		{
+			"bad extern",
+			"x: extern i64\n",
+			"'mem' or procedure type",
+		},
+		{
+			"missing flow",
+			"foo{\n\n\n\n}:\nb0:\n\tadd:i64 1, 2 -> '0:i64\n",
+			"flow",
+		},
+		{
+			"literal out of range",
+			"foo{\n\n\n\n}:\nb0:\n\tcopy:i64 -99999999999999999999 -> '0:i64\n\tret\n",
+			"out of range",
+		},
+	}
+	for _, tt := range tests {
+		_, err := Program(tt.input)
+		if err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+			continue
+		}
+		if err.Code != EC.Parse {
+			t.Errorf("%v: error code is %v, expected %v", tt.name, err.Code, EC.Parse)
+		}
+		if !strings.Contains(err.Message, tt.message) {
+			t.Errorf("%v: error %q doesn't mention %q", tt.name, err.Message, tt.message)
+		}
+	}
+}
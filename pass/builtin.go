@@ -0,0 +1,65 @@
+package pass
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/constfold"
+	"github.com/padeir0/pir/dce"
+	. "github.com/padeir0/pir/errors"
+	"github.com/padeir0/pir/lint"
+	"github.com/padeir0/pir/uninit"
+)
+
+func init() {
+	Register(&Pass{Name: "constfold", Procedure: foldConstants})
+	Register(&Pass{Name: "dce", Procedure: deadInstrs})
+	Register(&Pass{Name: "remove-unreachable", Procedure: unreachableBlocks})
+	Register(&Pass{Name: "zero-init", Procedure: zeroInit})
+	Register(&Pass{Name: "uninit", Procedure: uninitWarn})
+	Register(&Pass{Name: "lint", Procedure: lintAll})
+}
+
+// folding conditions leaves unreachable blocks, which the checker
+// rejects, so they're removed right away
+func foldConstants(m *Manager, proc *pir.Procedure) (bool, *Error) {
+	changed := constfold.Procedure(proc)
+	if changed {
+		dce.Blocks(proc)
+	}
+	return changed, nil
+}
+
+// runs until nothing else dies
+func deadInstrs(m *Manager, proc *pir.Procedure) (bool, *Error) {
+	changed := false
+	for dce.Instrs(proc, m.Liveness(proc)) {
+		m.Invalidate(proc)
+		changed = true
+	}
+	return changed, nil
+}
+
+func unreachableBlocks(m *Manager, proc *pir.Procedure) (bool, *Error) {
+	return dce.Blocks(proc), nil
+}
+
+func zeroInit(m *Manager, proc *pir.Procedure) (bool, *Error) {
+	if len(proc.AllBlocks) == 0 {
+		return false, nil
+	}
+	// the copies go either in the start block or in a new one
+	blocks := len(proc.AllBlocks)
+	before := len(proc.AllBlocks[proc.Start].Code)
+	uninit.Procedure(proc, uninit.ZeroInit)
+	changed := len(proc.AllBlocks) != blocks || len(proc.AllBlocks[proc.Start].Code) != before
+	return changed, nil
+}
+
+func uninitWarn(m *Manager, proc *pir.Procedure) (bool, *Error) {
+	m.Report(uninit.Procedure(proc, uninit.Warn)...)
+	return false, nil
+}
+
+func lintAll(m *Manager, proc *pir.Procedure) (bool, *Error) {
+	m.Report(lint.Procedure(proc, lint.All)...)
+	return false, nil
+}
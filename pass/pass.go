@@ -0,0 +1,248 @@
+package pass
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/callgraph"
+	"github.com/padeir0/pir/cfg"
+	pirchecker "github.com/padeir0/pir/checker"
+	"github.com/padeir0/pir/defuse"
+	"github.com/padeir0/pir/dom"
+	. "github.com/padeir0/pir/errors"
+	eu "github.com/padeir0/pir/errors/util"
+	"github.com/padeir0/pir/liveness"
+	"github.com/padeir0/pir/loops"
+
+	"sort"
+	"strings"
+)
+
+/*
+Runs pipelines of passes over a program.
+
+A pipeline is a list of pass names separated by commas, such as
+"constfold,dce", presets like "O1" expand to their own pipelines.
+Passes may ask the manager for analyses, which are cached until a pass
+reports that it changed the procedure, or the program, they were computed on.
+
+The program is checked before the first pass, and, in debug mode,
+after every pass, so that a broken pass is caught where it happens.
+It's checked as a module, so unlinked modules can be optimized too.
+*/
+
+type Pass struct {
+	Name string
+	// exactly one of these is set, procedure passes run
+	// on every procedure that is not a builtin or extern
+	Program   func(m *Manager, P *pir.Program) (changed bool, err *Error)
+	Procedure func(m *Manager, proc *pir.Procedure) (changed bool, err *Error)
+}
+
+var registry = map[string]*Pass{}
+
+// Register makes p available to pipelines, names must be unique.
+func Register(p *Pass) {
+	if _, ok := registry[p.Name]; ok {
+		panic("pass registered twice: " + p.Name)
+	}
+	if (p.Program == nil) == (p.Procedure == nil) {
+		panic("pass must run on either programs or procedures: " + p.Name)
+	}
+	registry[p.Name] = p
+}
+
+func Lookup(name string) (*Pass, bool) {
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names returns the names of every registered pass, sorted.
+func Names() []string {
+	output := make([]string, 0, len(registry))
+	for name := range registry {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output
+}
+
+// Presets maps optimization levels to their pipelines,
+// a leading dash is accepted, so that "-O2" is the same as "O2".
+var Presets = map[string]string{
+	"O0": "",
+	"O1": "constfold,dce",
+	"O2": "constfold,dce,constfold,dce",
+}
+
+// Parse resolves a pipeline of comma separated names.
+func Parse(pipeline string) ([]*Pass, *Error) {
+	names := []string{}
+	for _, name := range strings.Split(pipeline, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return Resolve(names)
+}
+
+// Resolve is the same as Parse, but for a list of names.
+func Resolve(names []string) ([]*Pass, *Error) {
+	output := []*Pass{}
+	for _, name := range names {
+		if preset, ok := Presets[strings.TrimPrefix(name, "-")]; ok {
+			passes, err := Parse(preset)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, passes...)
+			continue
+		}
+		p, ok := registry[name]
+		if !ok {
+			return nil, unknownPass(name)
+		}
+		output = append(output, p)
+	}
+	return output, nil
+}
+
+type Manager struct {
+	Program *pir.Program
+	// check the program after every pass
+	Debug bool
+	// warnings reported by the passes
+	Diags []*Error
+
+	cache map[key]interface{}
+}
+
+// analyses of the whole program have a nil proc
+type key struct {
+	name string
+	proc *pir.Procedure
+}
+
+func New(P *pir.Program) *Manager {
+	return &Manager{
+		Program: P,
+		Diags:   []*Error{},
+		cache:   map[key]interface{}{},
+	}
+}
+
+// Run checks the program and runs the passes in order,
+// it stops at the first error.
+func (this *Manager) Run(passes []*Pass) *Error {
+	err := pirchecker.CheckModule(this.Program)
+	if err != nil {
+		return err
+	}
+	for _, p := range passes {
+		err = this.runPass(p)
+		if err != nil {
+			return err
+		}
+		if this.Debug {
+			err = pirchecker.CheckModule(this.Program)
+			if err != nil {
+				err.Message = "after " + p.Name + ": " + err.Message
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunPipeline is the same as Run, but parses the pipeline first.
+func (this *Manager) RunPipeline(pipeline string) *Error {
+	passes, err := Parse(pipeline)
+	if err != nil {
+		return err
+	}
+	return this.Run(passes)
+}
+
+func (this *Manager) runPass(p *Pass) *Error {
+	if p.Program != nil {
+		changed, err := p.Program(this, this.Program)
+		if changed {
+			this.InvalidateAll()
+		}
+		return err
+	}
+	for _, sy := range this.Program.Symbols {
+		if sy == nil || sy.Proc == nil || sy.Builtin || sy.Extern {
+			continue
+		}
+		changed, err := p.Procedure(this, sy.Proc)
+		if changed {
+			this.Invalidate(sy.Proc)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report adds a warning to the diagnostics of the manager.
+func (this *Manager) Report(diags ...*Error) {
+	this.Diags = append(this.Diags, diags...)
+}
+
+// Invalidate drops the analyses of proc, and the ones of the whole program.
+func (this *Manager) Invalidate(proc *pir.Procedure) {
+	for k := range this.cache {
+		if k.proc == proc || k.proc == nil {
+			delete(this.cache, k)
+		}
+	}
+}
+
+func (this *Manager) InvalidateAll() {
+	this.cache = map[key]interface{}{}
+}
+
+func (this *Manager) CFG(proc *pir.Procedure) *cfg.CFG {
+	return this.get("cfg", proc, func() interface{} { return cfg.New(proc) }).(*cfg.CFG)
+}
+
+func (this *Manager) Dom(proc *pir.Procedure) *dom.Tree {
+	return this.get("dom", proc, func() interface{} { return dom.New(proc) }).(*dom.Tree)
+}
+
+func (this *Manager) PostDom(proc *pir.Procedure) *dom.Tree {
+	return this.get("postdom", proc, func() interface{} { return dom.NewPost(proc) }).(*dom.Tree)
+}
+
+func (this *Manager) Liveness(proc *pir.Procedure) *liveness.Liveness {
+	return this.get("liveness", proc, func() interface{} { return liveness.New(proc) }).(*liveness.Liveness)
+}
+
+func (this *Manager) Loops(proc *pir.Procedure) *loops.Forest {
+	return this.get("loops", proc, func() interface{} { return loops.New(proc) }).(*loops.Forest)
+}
+
+// the chains keep themselves up to date on ReplaceAllUsesWith,
+// but are still dropped when the pass reports a change
+func (this *Manager) DefUse(proc *pir.Procedure) *defuse.Chains {
+	return this.get("defuse", proc, func() interface{} { return defuse.New(proc) }).(*defuse.Chains)
+}
+
+func (this *Manager) CallGraph() *callgraph.Graph {
+	return this.get("callgraph", nil, func() interface{} { return callgraph.New(this.Program) }).(*callgraph.Graph)
+}
+
+func (this *Manager) get(name string, proc *pir.Procedure, compute func() interface{}) interface{} {
+	k := key{name: name, proc: proc}
+	if a, ok := this.cache[k]; ok {
+		return a
+	}
+	a := compute()
+	this.cache[k] = a
+	return a
+}
+
+func unknownPass(name string) *Error {
+	return eu.NewPipelineError("unknown pass: " + name)
+}
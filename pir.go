@@ -5,6 +5,7 @@ import (
 	hirc "github.com/padeir0/pir/class"
 	FT "github.com/padeir0/pir/flowkind"
 	IT "github.com/padeir0/pir/instrkind"
+	"github.com/padeir0/pir/span"
 	T "github.com/padeir0/pir/types"
 	"strconv"
 	"strings"
@@ -13,6 +14,9 @@ import (
 // index into Program.Symbols array
 type SymbolID int
 
+// Entry of modules without an entry point, see link.Link
+const NoEntry SymbolID = -1
+
 type Program struct {
 	Name    string
 	Entry   SymbolID
@@ -37,6 +41,22 @@ func (this *Program) AddMem(m *MemoryDecl) int {
 	return index
 }
 
+// AddExternProc declares a procedure defined in another module,
+// only the label, arguments and returns of p are used.
+func (this *Program) AddExternProc(p *Procedure) int {
+	index := len(this.Symbols)
+	this.Symbols = append(this.Symbols, &Symbol{Proc: p, Extern: true})
+	return index
+}
+
+// AddExternMem declares memory defined in another module,
+// only the label of m is used.
+func (this *Program) AddExternMem(m *MemoryDecl) int {
+	index := len(this.Symbols)
+	this.Symbols = append(this.Symbols, &Symbol{Mem: m, Extern: true})
+	return index
+}
+
 func (this *Program) String() string {
 	if this == nil {
 		return "nil program"
@@ -56,12 +76,21 @@ type Symbol struct {
 	Proc    *Procedure
 	Mem     *MemoryDecl
 	Builtin bool
+	// declared here but defined in another module, see link.Link
+	Extern bool
 }
 
 func (this *Symbol) String() string {
 	if this.Builtin {
 		return this.Proc.Label + ": " + "builtin"
 	}
+	if this.Extern {
+		if this.Proc != nil {
+			sig := &T.ProcType{Args: this.Proc.Args, Rets: this.Proc.Rets}
+			return this.Proc.Label + ": extern " + sig.String()
+		}
+		return this.Mem.Label + ": extern mem"
+	}
 	if this.Proc != nil {
 		return this.Proc.String()
 	}
@@ -89,6 +118,8 @@ type Procedure struct {
 
 	Start     BlockID
 	AllBlocks []*BasicBlock
+
+	Span *span.Span // optional
 }
 
 func (this *Procedure) FirstBlock() *BasicBlock {
@@ -135,7 +166,7 @@ func StrTypes(tps []*T.Type) string {
 		return tps[0].String()
 	}
 	output := tps[0].String()
-	for _, t := range tps {
+	for _, t := range tps[1:] {
 		output += ", " + t.String()
 	}
 	return output
@@ -233,6 +264,8 @@ type Flow struct {
 	V     []Operand
 	True  BlockID
 	False BlockID
+
+	Span *span.Span // optional
 }
 
 func (this *Flow) String() string {
@@ -263,7 +296,8 @@ func (this *Flow) StrRets() string {
 type Operand struct {
 	Class hirc.Class
 	Type  *T.Type
-	Num   uint64
+	// literals of signed types are sign extended, see util.IntLit
+	Num uint64
 }
 
 func (this *Operand) String() string {
@@ -281,6 +315,9 @@ func (this *Operand) String() string {
 	case hirc.Global:
 		return "global#" + value + ":" + this.Type.String()
 	case hirc.Lit:
+		if this.Type != nil && T.IsInt(this.Type) {
+			return strconv.FormatInt(int64(this.Num), 10)
+		}
 		return value
 	}
 	return "?"
@@ -291,6 +328,8 @@ type Instr struct {
 	Type        *T.Type
 	Operands    []Operand
 	Destination []Operand
+
+	Span *span.Span // optional
 }
 
 func (this *Instr) String() string {
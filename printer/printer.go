@@ -0,0 +1,233 @@
+package printer
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	FT "github.com/padeir0/pir/flowkind"
+	T "github.com/padeir0/pir/types"
+
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+Canonical format, meant to be stable and diffable:
+
+	program <name>
+	entry <label>
+
+	builtin <label>
+	extern proc <label> proc[<args>][<rets>]
+	extern mem <label>
+	mem <label> <size>
+	mem <label> "<data>"
+	proc <label> proc[<args>][<rets>]
+		vars <types>
+		start .L<id>
+	.L<id> <label>:
+		<kind>:<type> <operands> -> <destinations>
+		<flow>
+
+Globals are printed as @<label>:<type>, and literals always carry their type,
+literals of signed types are printed with their sign.
+*/
+
+// Program writes P to w in the canonical format.
+// The output depends only on P, so it can be used in golden tests.
+func Program(w io.Writer, P *pir.Program) error {
+	p := &printer{w: w, program: P}
+	p.Line("program " + P.Name)
+	p.Line("entry " + entry(P))
+	for _, sy := range P.Symbols {
+		p.Line("")
+		symbol(p, sy)
+	}
+	return p.err
+}
+
+// Procedure writes a single procedure of P to w in the canonical format.
+func Procedure(w io.Writer, P *pir.Program, proc *pir.Procedure) error {
+	p := &printer{w: w, program: P}
+	procedure(p, proc)
+	return p.err
+}
+
+// String returns the canonical format of P.
+func String(P *pir.Program) string {
+	var sb strings.Builder
+	Program(&sb, P) // writing to a strings.Builder never fails
+	return sb.String()
+}
+
+type printer struct {
+	w       io.Writer
+	program *pir.Program
+	err     error
+}
+
+// after the first error, nothing else is written
+func (p *printer) Line(s string) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = io.WriteString(p.w, s+"\n")
+}
+
+func entry(P *pir.Program) string {
+	if P.Entry < 0 || int(P.Entry) >= len(P.Symbols) {
+		return "#" + strconv.Itoa(int(P.Entry))
+	}
+	return symbolLabel(P.Symbols[P.Entry])
+}
+
+func symbol(p *printer, sy *pir.Symbol) {
+	if sy == nil {
+		p.Line("nil")
+		return
+	}
+	if sy.Builtin {
+		p.Line("builtin " + symbolLabel(sy))
+		return
+	}
+	if sy.Extern {
+		if sy.Proc != nil {
+			sig := &T.ProcType{Args: sy.Proc.Args, Rets: sy.Proc.Rets}
+			p.Line("extern proc " + sy.Proc.Label + " " + sig.String())
+			return
+		}
+		if sy.Mem != nil {
+			p.Line("extern mem " + sy.Mem.Label)
+			return
+		}
+	}
+	if sy.Proc != nil {
+		procedure(p, sy.Proc)
+		return
+	}
+	if sy.Mem != nil {
+		if sy.Mem.Data != "" {
+			p.Line("mem " + sy.Mem.Label + " " + sy.Mem.Data)
+			return
+		}
+		p.Line("mem " + sy.Mem.Label + " " + strconv.FormatUint(sy.Mem.Size, 10))
+		return
+	}
+	p.Line("invalid symbol")
+}
+
+func symbolLabel(sy *pir.Symbol) string {
+	if sy == nil {
+		return "nil"
+	}
+	if sy.Proc != nil {
+		return sy.Proc.Label
+	}
+	if sy.Mem != nil {
+		return sy.Mem.Label
+	}
+	return "?"
+}
+
+func procedure(p *printer, proc *pir.Procedure) {
+	sig := &T.ProcType{Args: proc.Args, Rets: proc.Rets}
+	p.Line("proc " + proc.Label + " " + sig.String())
+	if len(proc.Vars) == 0 {
+		p.Line("\tvars")
+	} else {
+		p.Line("\tvars " + types(proc.Vars))
+	}
+	p.Line("\tstart " + blockID(proc.Start))
+	for i, bb := range proc.AllBlocks {
+		block(p, pir.BlockID(i), bb)
+	}
+}
+
+func block(p *printer, id pir.BlockID, bb *pir.BasicBlock) {
+	if bb == nil {
+		p.Line(blockID(id) + " nil")
+		return
+	}
+	p.Line(blockID(id) + " " + bb.Label + ":")
+	for _, instr := range bb.Code {
+		p.Line("\t" + Instr(p.program, instr))
+	}
+	p.Line("\t" + Flow(p.program, bb.Out))
+}
+
+// Instr returns the canonical format of an instruction of P.
+func Instr(P *pir.Program, instr pir.Instr) string {
+	output := instr.T.String()
+	if instr.Type != nil {
+		output += ":" + instr.Type.String()
+	}
+	if len(instr.Operands) > 0 {
+		output += " " + operands(P, instr.Operands)
+	}
+	if len(instr.Destination) > 0 {
+		output += " -> " + operands(P, instr.Destination)
+	}
+	return output
+}
+
+// Flow returns the canonical format of a flow of P.
+func Flow(P *pir.Program, f pir.Flow) string {
+	switch f.T {
+	case FT.Jmp:
+		return "jmp " + blockID(f.True)
+	case FT.If:
+		return "if " + operands(P, f.V) + " ? " + blockID(f.True) + " : " + blockID(f.False)
+	case FT.Return, FT.Exit:
+		if len(f.V) == 0 {
+			return f.T.String()
+		}
+		return f.T.String() + " " + operands(P, f.V)
+	}
+	return "invalid"
+}
+
+// Operand returns the canonical format of an operand of P,
+// globals are resolved to the label of their symbol.
+func Operand(P *pir.Program, op pir.Operand) string {
+	num := strconv.FormatUint(op.Num, 10)
+	t := op.Type.String()
+	switch op.Class {
+	case hirc.Temp:
+		return "'" + num + ":" + t
+	case hirc.Local:
+		return "local#" + num + ":" + t
+	case hirc.Arg:
+		return "arg#" + num + ":" + t
+	case hirc.Lit:
+		if op.Type != nil && T.IsInt(op.Type) {
+			num = strconv.FormatInt(int64(op.Num), 10)
+		}
+		return num + ":" + t
+	case hirc.Global:
+		if P != nil && op.Num < uint64(len(P.Symbols)) {
+			return "@" + symbolLabel(P.Symbols[op.Num]) + ":" + t
+		}
+		return "global#" + num + ":" + t
+	}
+	return "?"
+}
+
+func operands(P *pir.Program, ops []pir.Operand) string {
+	output := make([]string, len(ops))
+	for i, op := range ops {
+		output[i] = Operand(P, op)
+	}
+	return strings.Join(output, ", ")
+}
+
+func types(tps []*T.Type) string {
+	output := make([]string, len(tps))
+	for i, t := range tps {
+		output[i] = t.String()
+	}
+	return strings.Join(output, ", ")
+}
+
+func blockID(id pir.BlockID) string {
+	return ".L" + strconv.Itoa(int(id))
+}
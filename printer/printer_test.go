@@ -0,0 +1,126 @@
+package printer
+
+import (
+	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	"github.com/padeir0/pir/parse"
+	T "github.com/padeir0/pir/types"
+	"github.com/padeir0/pir/util"
+
+	"testing"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			"symbols",
+			`Program: symbols
+
+write: builtin
+buff: 64
+ext: extern proc[i64][]
+data: extern mem
+main{
+
+
+i64
+}:
+b0:
+	call, global#2:proc[i64][], 5:i64
+	storeptr:i64 7:i64, global#1:ptr
+	if 1? .L1 : .L1
+b1:
+	exit 0:i8
+`,
+			`program symbols
+entry main
+
+builtin write
+
+mem buff 64
+
+extern proc ext proc[i64][]
+
+extern mem data
+
+proc main proc[][]
+	vars i64
+	start .L0
+.L0 b0:
+	call @ext:proc[i64][], 5:i64
+	storeptr:i64 7:i64, @buff:ptr
+	if 1:bool ? .L1 : .L1
+.L1 b1:
+	exit 0:i8
+`,
+		},
+		{
+			"literals",
+			`Program: literals
+
+id{
+i8
+i8
+
+}:
+b0:
+	copy:i8 -1 -> '0:i8
+	add:i8 arg#0:i8, -128 -> '1:i8
+	copy:u8 255 -> '2:u8
+	copy:i64 -9223372036854775808 -> '3:i64
+	ret '1:i8
+`,
+			`program literals
+entry #-1
+
+proc id proc[i8][i8]
+	vars
+	start .L0
+.L0 b0:
+	copy:i8 -1:i8 -> '0:i8
+	add:i8 arg#0:i8, -128:i8 -> '1:i8
+	copy:u8 255:u8 -> '2:u8
+	copy:i64 -9223372036854775808:i64 -> '3:i64
+	ret '1:i8
+`,
+		},
+	}
+	for _, tt := range tests {
+		P, err := parse.Program(tt.input)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if output := String(P); output != tt.output {
+			t.Errorf("%v: got:\n%v\nexpected:\n%v", tt.name, output, tt.output)
+		}
+	}
+}
+
+func TestOperand(t *testing.T) {
+	P := pir.NewProgram()
+	P.AddMem(&pir.MemoryDecl{Label: "buff", Size: 8})
+	tests := []struct {
+		op     pir.Operand
+		output string
+	}{
+		{util.IntLit(T.T_I8, -1), "-1:i8"},
+		{util.IntLit(T.T_I64, -9223372036854775808), "-9223372036854775808:i64"},
+		{util.UintLit(T.T_U64, 1<<64-1), "18446744073709551615:u64"},
+		{util.UintLit(T.T_Ptr, 16), "16:ptr"},
+		{util.BoolLit(true), "1:bool"},
+		{pir.Operand{Class: hirc.Temp, Num: 3, Type: T.T_I32}, "'3:i32"},
+		{pir.Operand{Class: hirc.Local, Num: 0, Type: T.T_U16}, "local#0:u16"},
+		{pir.Operand{Class: hirc.Arg, Num: 1, Type: T.T_I16}, "arg#1:i16"},
+		{pir.Operand{Class: hirc.Global, Num: 0, Type: T.T_Ptr}, "@buff:ptr"},
+		{pir.Operand{Class: hirc.Global, Num: 1, Type: T.T_Ptr}, "global#1:ptr"},
+	}
+	for _, tt := range tests {
+		if output := Operand(P, tt.op); output != tt.output {
+			t.Errorf("got %v, expected %v", output, tt.output)
+		}
+	}
+}
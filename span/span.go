@@ -0,0 +1,25 @@
+package span
+
+import (
+	"strconv"
+)
+
+// Span is a position in the frontend source,
+// Line and Col start at 1, File may be empty.
+// A nil *Span means the position is unknown.
+type Span struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (this *Span) String() string {
+	if this == nil {
+		return "?"
+	}
+	pos := strconv.Itoa(this.Line) + ":" + strconv.Itoa(this.Col)
+	if this.File == "" {
+		return pos
+	}
+	return this.File + ":" + pos
+}
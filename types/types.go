@@ -137,6 +137,81 @@ var T_Void = &Type{Special: Void}
 var T_MultiRet = &Type{Special: MultiRet}
 var T_MainProc = &Type{Proc: &ProcType{Args: []*Type{}, Rets: []*Type{}}}
 
+var named = map[string]*Type{
+	"i8":       T_I8,
+	"i16":      T_I16,
+	"i32":      T_I32,
+	"i64":      T_I64,
+	"u8":       T_U8,
+	"u16":      T_U16,
+	"u32":      T_U32,
+	"u64":      T_U64,
+	"bool":     T_Bool,
+	"ptr":      T_Ptr,
+	"MultiRet": T_MultiRet,
+	"Void":     T_Void,
+}
+
+// Named returns the basic or special type printed as name,
+// procedure types have no name.
+func Named(name string) (*Type, bool) {
+	t, ok := named[name]
+	return t, ok
+}
+
+// Interner makes equal types the same object, like the types created
+// by a frontend. The MIR checker compares the types of values in
+// registers by pointer, so types coming from different places must be
+// interned before code generation. Basic and special types are always
+// the ones declared in this package.
+type Interner struct {
+	types map[string]*Type
+}
+
+func NewInterner() *Interner {
+	types := make(map[string]*Type, len(named))
+	for name, t := range named {
+		types[name] = t
+	}
+	return &Interner{types: types}
+}
+
+// Intern returns the type equal to t that was seen first,
+// nil is kept as nil.
+func (this *Interner) Intern(t *Type) *Type {
+	if t == nil {
+		return nil
+	}
+	key := t.String()
+	if old, ok := this.types[key]; ok {
+		return old
+	}
+	if IsProc(t) {
+		t = &Type{Proc: &ProcType{
+			Args: this.InternAll(t.Proc.Args),
+			Rets: this.InternAll(t.Proc.Rets),
+		}}
+	}
+	this.types[key] = t
+	return t
+}
+
+func (this *Interner) InternAll(tps []*Type) []*Type {
+	if tps == nil {
+		return nil
+	}
+	output := make([]*Type, len(tps))
+	for i, t := range tps {
+		output[i] = this.Intern(t)
+	}
+	return output
+}
+
+// Proc returns the procedure type with these arguments and returns.
+func (this *Interner) Proc(args, rets []*Type) *Type {
+	return this.Intern(&Type{Proc: &ProcType{Args: args, Rets: rets}})
+}
+
 type BasicType int
 
 const (
@@ -0,0 +1,248 @@
+package uninit
+
+import (
+	"github.com/padeir0/pir"
+	"github.com/padeir0/pir/cfg"
+	hirc "github.com/padeir0/pir/class"
+	. "github.com/padeir0/pir/errors"
+	EC "github.com/padeir0/pir/errors/code"
+	eu "github.com/padeir0/pir/errors/util"
+	"github.com/padeir0/pir/span"
+	T "github.com/padeir0/pir/types"
+	"github.com/padeir0/pir/util"
+
+	"fmt"
+	"strconv"
+)
+
+/*
+Finds reads of locals that are not definitely assigned.
+
+Locals live in the stack frame and are never zeroed, so reading one
+before writing it on every path from the start block reads garbage.
+This is a forward "must" dataflow analysis: a local is assigned at the
+start of a block only if it's assigned at the end of all its predecessors.
+Unreachable blocks are ignored, the checker already reports them.
+
+The program must be valid, see checker.Check.
+*/
+
+type Mode int
+
+const (
+	// report uninitialized reads as warnings
+	Warn Mode = iota
+	// report uninitialized reads as errors
+	Fail
+	// insert copies of 0 to the uninitialized locals at the start
+	// of the procedure, nothing is reported
+	ZeroInit
+)
+
+// Check analyses every procedure of P, in ZeroInit mode P is modified.
+func Check(P *pir.Program, mode Mode) []*Error {
+	diags := []*Error{}
+	for _, sy := range P.Symbols {
+		if sy == nil || sy.Proc == nil || sy.Builtin || sy.Extern {
+			continue
+		}
+		diags = append(diags, Procedure(sy.Proc, mode)...)
+	}
+	return diags
+}
+
+// Procedure is the same as Check, but for a single procedure.
+func Procedure(proc *pir.Procedure, mode Mode) []*Error {
+	if len(proc.Vars) == 0 || len(proc.AllBlocks) == 0 {
+		return nil
+	}
+	in := assignedAtStart(proc)
+	diags := []*Error{}
+	for i := range proc.AllBlocks {
+		if in[i] == nil {
+			continue
+		}
+		diags = append(diags, checkBlock(proc, pir.BlockID(i), in[i], mode)...)
+	}
+	if mode != ZeroInit {
+		return diags
+	}
+	zeroInit(proc, diags)
+	return nil
+}
+
+// set of locals, indexed by their number
+type set []bool
+
+func full(size int) set {
+	s := make(set, size)
+	for i := range s {
+		s[i] = true
+	}
+	return s
+}
+
+func (s set) Copy() set {
+	out := make(set, len(s))
+	copy(out, s)
+	return out
+}
+
+func (s set) Intersect(other set) {
+	for i := range s {
+		s[i] = s[i] && other[i]
+	}
+}
+
+func (s set) Equals(other set) bool {
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// the locals definitely assigned at the start of each block,
+// nil for unreachable blocks
+func assignedAtStart(proc *pir.Procedure) []set {
+	n := len(proc.Vars)
+	g := cfg.New(proc)
+	in := make([]set, len(proc.AllBlocks))
+	out := make([]set, len(proc.AllBlocks))
+	for _, id := range g.ReversePostOrder {
+		in[id] = full(n)
+		out[id] = full(n)
+	}
+	in[g.Start] = make(set, n)
+
+	changed := true
+	for changed {
+		changed = false
+		for _, id := range g.ReversePostOrder {
+			newOut := in[id].Copy()
+			for _, instr := range proc.AllBlocks[id].Code {
+				assign(newOut, instr.Destination)
+			}
+			if newOut.Equals(out[id]) {
+				continue
+			}
+			out[id] = newOut
+			changed = true
+			for _, succ := range g.Succs[id] {
+				if succ != g.Start {
+					in[succ].Intersect(newOut)
+				}
+			}
+		}
+	}
+	return in
+}
+
+func checkBlock(proc *pir.Procedure, id pir.BlockID, in set, mode Mode) []*Error {
+	bb := proc.AllBlocks[id]
+	assigned := in.Copy()
+	diags := []*Error{}
+	for i := range bb.Code {
+		instr := &bb.Code[i]
+		for _, op := range reads(assigned, instr.Operands) {
+			err := uninitialized(mode, instr, op)
+			locate(err, proc, id, i, instr.Span)
+			diags = append(diags, err)
+		}
+		assign(assigned, instr.Destination)
+	}
+	for _, op := range reads(assigned, bb.Out.V) {
+		err := uninitialized(mode, &bb.Out, op)
+		locate(err, proc, id, -1, bb.Out.Span)
+		diags = append(diags, err)
+	}
+	return diags
+}
+
+// returns the locals in ops that are not assigned
+func reads(assigned set, ops []pir.Operand) []pir.Operand {
+	output := []pir.Operand{}
+	for _, op := range ops {
+		if op.Class == hirc.Local && !assigned[op.Num] {
+			output = append(output, op)
+		}
+	}
+	return output
+}
+
+func assign(assigned set, dests []pir.Operand) {
+	for _, dest := range dests {
+		if dest.Class == hirc.Local {
+			assigned[dest.Num] = true
+		}
+	}
+}
+
+// each local is initialized once, in order of appearance.
+// If the start block is the target of a jump, the copies would run
+// again every time, so they go in a new block that jumps to it
+func zeroInit(proc *pir.Procedure, diags []*Error) {
+	done := map[uint64]bool{}
+	code := []pir.Instr{}
+	for _, err := range diags {
+		local := *err.Operand.(*pir.Operand)
+		if done[local.Num] {
+			continue
+		}
+		done[local.Num] = true
+		local.Type = proc.Vars[local.Num]
+		code = append(code, util.Copy(zero(local.Type), local))
+	}
+	if len(code) == 0 {
+		return
+	}
+	start := proc.AllBlocks[proc.Start]
+	if len(cfg.New(proc).Preds[proc.Start]) == 0 {
+		start.Code = append(code, start.Code...)
+		return
+	}
+	entry := &pir.BasicBlock{
+		Label: entryLabel(proc),
+		Code:  code,
+		Out:   util.Jmp(proc.Start),
+	}
+	proc.Start = pir.BlockID(len(proc.AllBlocks))
+	proc.AllBlocks = append(proc.AllBlocks, entry)
+}
+
+func entryLabel(proc *pir.Procedure) string {
+	labels := map[string]bool{}
+	for _, bb := range proc.AllBlocks {
+		if bb != nil {
+			labels[bb.Label] = true
+		}
+	}
+	label := proc.Label + "_entry"
+	for i := 0; labels[label]; i++ {
+		label = proc.Label + "_entry" + strconv.Itoa(i)
+	}
+	return label
+}
+
+func zero(t *T.Type) pir.Operand {
+	return pir.Operand{Class: hirc.Lit, Type: t, Num: 0}
+}
+
+func locate(err *Error, proc *pir.Procedure, id pir.BlockID, index int, sp *span.Span) {
+	err.Proc = proc.Label
+	err.Block = int(id)
+	err.Index = index
+	if sp == nil {
+		sp = proc.Span
+	}
+	eu.WithSpan(sp, err)
+}
+
+func uninitialized(mode Mode, instr fmt.Stringer, op pir.Operand) *Error {
+	message := "local " + op.String() + " may be used before being assigned"
+	if mode == Fail {
+		return eu.NewCheckError(EC.UninitializedLocal, instr, &op, message)
+	}
+	return eu.NewCheckWarning(EC.UninitializedLocal, instr, &op, message)
+}
@@ -2,9 +2,29 @@ package util
 
 import (
 	"github.com/padeir0/pir"
+	hirc "github.com/padeir0/pir/class"
+	FT "github.com/padeir0/pir/flowkind"
 	IT "github.com/padeir0/pir/instrkind"
+	T "github.com/padeir0/pir/types"
 )
 
+// IntLit returns a literal of the signed type t,
+// the value is stored sign extended, as the checker expects.
+func IntLit(t *T.Type, value int64) pir.Operand {
+	return pir.Operand{Class: hirc.Lit, Type: t, Num: uint64(value)}
+}
+
+func UintLit(t *T.Type, value uint64) pir.Operand {
+	return pir.Operand{Class: hirc.Lit, Type: t, Num: value}
+}
+
+func BoolLit(value bool) pir.Operand {
+	if value {
+		return pir.Operand{Class: hirc.Lit, Type: T.T_Bool, Num: 1}
+	}
+	return pir.Operand{Class: hirc.Lit, Type: T.T_Bool, Num: 0}
+}
+
 func StorePtr(source, ptr pir.Operand) pir.Instr {
 	return pir.Instr{
 		T:        IT.StorePtr,
@@ -39,3 +59,90 @@ func Copy(source, destination pir.Operand) pir.Instr {
 		Destination: []pir.Operand{destination},
 	}
 }
+
+func Add(a, b, dest pir.Operand) pir.Instr  { return binary(IT.Add, a, b, dest) }
+func Sub(a, b, dest pir.Operand) pir.Instr  { return binary(IT.Sub, a, b, dest) }
+func Div(a, b, dest pir.Operand) pir.Instr  { return binary(IT.Div, a, b, dest) }
+func Mult(a, b, dest pir.Operand) pir.Instr { return binary(IT.Mult, a, b, dest) }
+func Rem(a, b, dest pir.Operand) pir.Instr  { return binary(IT.Rem, a, b, dest) }
+
+// comparisons are typed by their operands, dest must be bool
+func Eq(a, b, dest pir.Operand) pir.Instr     { return binary(IT.Eq, a, b, dest) }
+func Diff(a, b, dest pir.Operand) pir.Instr   { return binary(IT.Diff, a, b, dest) }
+func Less(a, b, dest pir.Operand) pir.Instr   { return binary(IT.Less, a, b, dest) }
+func More(a, b, dest pir.Operand) pir.Instr   { return binary(IT.More, a, b, dest) }
+func LessEq(a, b, dest pir.Operand) pir.Instr { return binary(IT.LessEq, a, b, dest) }
+func MoreEq(a, b, dest pir.Operand) pir.Instr { return binary(IT.MoreEq, a, b, dest) }
+
+func Or(a, b, dest pir.Operand) pir.Instr  { return binary(IT.Or, a, b, dest) }
+func And(a, b, dest pir.Operand) pir.Instr { return binary(IT.And, a, b, dest) }
+func Xor(a, b, dest pir.Operand) pir.Instr { return binary(IT.Xor, a, b, dest) }
+
+func ShiftLeft(a, b, dest pir.Operand) pir.Instr  { return binary(IT.ShiftLeft, a, b, dest) }
+func ShiftRight(a, b, dest pir.Operand) pir.Instr { return binary(IT.ShiftRight, a, b, dest) }
+
+func Not(a, dest pir.Operand) pir.Instr { return unary(IT.Not, a, dest) }
+func Neg(a, dest pir.Operand) pir.Instr { return unary(IT.Neg, a, dest) }
+
+// Call is untyped, the types come from the procedure operand
+func Call(proc pir.Operand, args, rets []pir.Operand) pir.Instr {
+	if rets == nil {
+		rets = []pir.Operand{}
+	}
+	return pir.Instr{
+		T:           IT.Call,
+		Operands:    append([]pir.Operand{proc}, args...),
+		Destination: rets,
+	}
+}
+
+func binary(kind IT.InstrKind, a, b, dest pir.Operand) pir.Instr {
+	return pir.Instr{
+		T:           kind,
+		Type:        a.Type,
+		Operands:    []pir.Operand{a, b},
+		Destination: []pir.Operand{dest},
+	}
+}
+
+func unary(kind IT.InstrKind, a, dest pir.Operand) pir.Instr {
+	return pir.Instr{
+		T:           kind,
+		Type:        a.Type,
+		Operands:    []pir.Operand{a},
+		Destination: []pir.Operand{dest},
+	}
+}
+
+func Jmp(target pir.BlockID) pir.Flow {
+	return pir.Flow{
+		T:    FT.Jmp,
+		True: target,
+	}
+}
+
+func Branch(cond pir.Operand, True, False pir.BlockID) pir.Flow {
+	return pir.Flow{
+		T:     FT.If,
+		V:     []pir.Operand{cond},
+		True:  True,
+		False: False,
+	}
+}
+
+func Return(rets ...pir.Operand) pir.Flow {
+	if rets == nil {
+		rets = []pir.Operand{}
+	}
+	return pir.Flow{
+		T: FT.Return,
+		V: rets,
+	}
+}
+
+func Exit(code pir.Operand) pir.Flow {
+	return pir.Flow{
+		T: FT.Exit,
+		V: []pir.Operand{code},
+	}
+}